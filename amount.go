@@ -0,0 +1,140 @@
+package nuonuo
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	amountPrec   = 2 // 金额、税额保留两位小数
+	quantityPrec = 8 // 单价、数量最多保留八位小数
+)
+
+// parseDecimal 解析接口中字符串形式的数值，空串视为零。
+func parseDecimal(s string) (*big.Rat, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return new(big.Rat), nil
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal: %q", s)
+	}
+
+	return r, nil
+}
+
+// formatAmount 按两位小数格式化金额，四舍五入。
+func formatAmount(r *big.Rat) string {
+	s := r.FloatString(amountPrec)
+	if s == "-0.00" {
+		return "0.00"
+	}
+
+	return s
+}
+
+// formatQuantity 格式化单价或数量，最多八位小数并去掉末尾的零。
+func formatQuantity(r *big.Rat) string {
+	s := r.FloatString(quantityPrec)
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+
+	if s == "-0" || s == "" {
+		return "0"
+	}
+
+	return s
+}
+
+// roundAmount 将数值按金额精度四舍五入。
+func roundAmount(r *big.Rat) *big.Rat {
+	v, _ := new(big.Rat).SetString(r.FloatString(amountPrec))
+	return v
+}
+
+// lineAmounts 计算明细行的不含税金额、含税金额与税额。
+// 已填写的字段直接采用，缺失的字段根据税率、单价与数量推算。
+func lineAmounts(item *GoodsItem) (excluded, included, tax *big.Rat, err error) {
+	rate, err := parseDecimal(item.TaxRate)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("taxRate: %w", err)
+	}
+
+	deduction, err := parseDecimal(item.Deduction)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("deduction: %w", err)
+	}
+
+	if item.TaxIncludedAmount != "" {
+		if included, err = parseDecimal(item.TaxIncludedAmount); err != nil {
+			return nil, nil, nil, fmt.Errorf("taxIncludedAmount: %w", err)
+		}
+	}
+
+	if item.TaxExcludedAmount != "" {
+		if excluded, err = parseDecimal(item.TaxExcludedAmount); err != nil {
+			return nil, nil, nil, fmt.Errorf("taxExcludedAmount: %w", err)
+		}
+	}
+
+	if item.Tax != "" {
+		if tax, err = parseDecimal(item.Tax); err != nil {
+			return nil, nil, nil, fmt.Errorf("tax: %w", err)
+		}
+	}
+
+	if included == nil && excluded == nil {
+		if item.Price == "" || item.Num == "" {
+			return nil, nil, nil, fmt.Errorf("goods %q: amount or price and num required", item.GoodsName)
+		}
+
+		price, err := parseDecimal(item.Price)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("price: %w", err)
+		}
+
+		num, err := parseDecimal(item.Num)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("num: %w", err)
+		}
+
+		amount := roundAmount(new(big.Rat).Mul(price, num))
+		if item.WithTaxFlag == "0" {
+			excluded = amount
+		} else {
+			included = amount
+		}
+	}
+
+	if tax == nil {
+		tax = calcTax(included, excluded, deduction, rate)
+	}
+
+	if included == nil {
+		included = new(big.Rat).Add(excluded, tax)
+	}
+
+	if excluded == nil {
+		excluded = new(big.Rat).Sub(included, tax)
+	}
+
+	return excluded, included, tax, nil
+}
+
+// calcTax 计算税额。差额征税时以扣除额后的部分作为计税依据。
+func calcTax(included, excluded, deduction, rate *big.Rat) *big.Rat {
+	if included != nil {
+		base := new(big.Rat).Sub(included, deduction)
+		onePlusRate := new(big.Rat).Add(big.NewRat(1, 1), rate)
+		base.Quo(base, onePlusRate)
+
+		return roundAmount(base.Mul(base, rate))
+	}
+
+	base := new(big.Rat).Sub(excluded, deduction)
+
+	return roundAmount(base.Mul(base, rate))
+}
@@ -0,0 +1,208 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	queryInvoiceMaxNos      = 50 // 发票详情查询接口单次最多查询的流水号或订单号数量
+	defaultBatchConcurrency = 4
+)
+
+type QueryInvoicesBatchRequest struct {
+	SerialNos            []string
+	OrderNos             []string
+	IsOfferInvoiceDetail string
+
+	ChunkSize   int // 每次查询的数量，默认且最大为50
+	Concurrency int // 并发查询数，默认4
+}
+
+type QueryInvoicesBatchResult struct {
+	BySerialNo map[string]*InvoiceResultItem
+	ByOrderNo  map[string][]*InvoiceResultItem
+
+	// 查询成功但平台未返回的流水号与订单号，查询失败分片中的不计入
+	MissingSerialNos []string
+	MissingOrderNos  []string
+
+	Errors []*ChunkError
+}
+
+// ChunkError 分片查询失败
+type ChunkError struct {
+	SerialNos []string
+	OrderNos  []string
+	Err       error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf(
+		"query chunk (serialNos: %s; orderNos: %s): %v",
+		strings.Join(e.SerialNos, ","), strings.Join(e.OrderNos, ","), e.Err,
+	)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+type queryChunk struct {
+	serialNos []string
+	orderNos  []string
+}
+
+// QueryInvoicesBatch 批量查询发票详情。
+// 流水号与订单号按接口上限分片后并发查询，部分分片失败时仍返回其余分片的结果，
+// 同时返回汇总了各分片错误的 error。
+func (c *Client) QueryInvoicesBatch(
+	ctx context.Context, req *QueryInvoicesBatchRequest,
+) (*QueryInvoicesBatchResult, error) {
+	size := req.ChunkSize
+	if size <= 0 || size > queryInvoiceMaxNos {
+		size = queryInvoiceMaxNos
+	}
+
+	concurrency := req.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	serialNos := uniqueStrings(req.SerialNos)
+	orderNos := uniqueStrings(req.OrderNos)
+
+	chunks := []queryChunk{}
+	for _, nos := range chunkStrings(serialNos, size) {
+		chunks = append(chunks, queryChunk{serialNos: nos})
+	}
+
+	for _, nos := range chunkStrings(orderNos, size) {
+		chunks = append(chunks, queryChunk{orderNos: nos})
+	}
+
+	result := &QueryInvoicesBatchResult{
+		BySerialNo: map[string]*InvoiceResultItem{},
+		ByOrderNo:  map[string][]*InvoiceResultItem{},
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, concurrency)
+		done = map[string]bool{} // 已成功查询的流水号与订单号
+	)
+
+	for i := range chunks {
+		chunk := chunks[i]
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				mu.Lock()
+				result.Errors = append(result.Errors, &ChunkError{
+					SerialNos: chunk.serialNos, OrderNos: chunk.orderNos, Err: ctx.Err(),
+				})
+				mu.Unlock()
+
+				return
+			}
+			defer func() { <-sem }()
+
+			items, err := c.QueryInvoice(ctx, &QueryInvoiceRequest{
+				SerialNos:            chunk.serialNos,
+				OrderNos:             chunk.orderNos,
+				IsOfferInvoiceDetail: req.IsOfferInvoiceDetail,
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				result.Errors = append(result.Errors, &ChunkError{
+					SerialNos: chunk.serialNos, OrderNos: chunk.orderNos, Err: err,
+				})
+
+				return
+			}
+
+			for _, no := range chunk.serialNos {
+				done["s:"+no] = true
+			}
+
+			for _, no := range chunk.orderNos {
+				done["o:"+no] = true
+			}
+
+			for _, item := range items {
+				result.BySerialNo[item.SerialNo] = item
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	for _, item := range result.BySerialNo {
+		result.ByOrderNo[item.OrderNo] = append(result.ByOrderNo[item.OrderNo], item)
+	}
+
+	for _, no := range serialNos {
+		if _, ok := result.BySerialNo[no]; !ok && done["s:"+no] {
+			result.MissingSerialNos = append(result.MissingSerialNos, no)
+		}
+	}
+
+	for _, no := range orderNos {
+		if _, ok := result.ByOrderNo[no]; !ok && done["o:"+no] {
+			result.MissingOrderNos = append(result.MissingOrderNos, no)
+		}
+	}
+
+	if len(result.Errors) > 0 {
+		errs := make([]error, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			errs = append(errs, e)
+		}
+
+		return result, errors.Join(errs...)
+	}
+
+	return result, nil
+}
+
+func chunkStrings(ss []string, size int) [][]string {
+	chunks := make([][]string, 0, (len(ss)+size-1)/size)
+	for size < len(ss) {
+		ss, chunks = ss[size:], append(chunks, ss[:size:size])
+	}
+
+	if len(ss) > 0 {
+		chunks = append(chunks, ss)
+	}
+
+	return chunks
+}
+
+func uniqueStrings(ss []string) []string {
+	seen := make(map[string]bool, len(ss))
+	out := make([]string, 0, len(ss))
+
+	for _, s := range ss {
+		if s == "" || seen[s] {
+			continue
+		}
+
+		seen[s] = true
+		out = append(out, s)
+	}
+
+	return out
+}
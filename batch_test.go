@@ -0,0 +1,86 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_QueryInvoicesBatch(t *testing.T) {
+	p := newFakePlatform(t)
+	p.handle("nuonuo.OpeMplatform.queryInvoiceResult", func(body []byte) (any, error) {
+		var req QueryInvoiceRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		if len(req.SerialNos) > queryInvoiceMaxNos || len(req.OrderNos) > queryInvoiceMaxNos {
+			return nil, &Error{Code: "E9999", Msg: "too many"}
+		}
+
+		items := []*InvoiceResultItem{}
+		for _, no := range req.SerialNos {
+			n, _ := strconv.Atoi(no[1:])
+
+			switch {
+			case n == 399:
+				// 该分片查询失败
+				return nil, fmt.Errorf("unavailable")
+			case n%100 == 7:
+				// 平台未返回
+				continue
+			}
+
+			items = append(items, &InvoiceResultItem{SerialNo: no, OrderNo: "O" + strconv.Itoa(n/2)})
+		}
+
+		for _, no := range req.OrderNos {
+			if no != "O-missing" {
+				items = append(items, &InvoiceResultItem{SerialNo: "X" + no, OrderNo: no})
+			}
+		}
+
+		return items, nil
+	})
+
+	serialNos := make([]string, 0, 401)
+	for i := 0; i < 400; i++ {
+		serialNos = append(serialNos, "S"+strconv.Itoa(i))
+	}
+
+	serialNos = append(serialNos, "S0") // 重复的流水号只查询一次
+
+	result, err := p.client().QueryInvoicesBatch(context.Background(), &QueryInvoicesBatchRequest{
+		SerialNos:   serialNos,
+		OrderNos:    []string{"O-a", "O-missing"},
+		Concurrency: 8,
+	})
+
+	var chunkErr *ChunkError
+	require.ErrorAs(t, err, &chunkErr)
+	assert.Len(t, result.Errors, 1)
+	assert.Contains(t, chunkErr.SerialNos, "S399")
+	assert.Len(t, chunkErr.SerialNos, queryInvoiceMaxNos)
+
+	assert.Equal(t, 9, p.count("nuonuo.OpeMplatform.queryInvoiceResult"))
+
+	// 失败分片 S350-S399 不计入结果与缺失列表
+	assert.Len(t, result.BySerialNo, 350-4+1)
+	assert.Equal(t, []string{"S7", "S107", "S207", "S307"}, result.MissingSerialNos)
+	assert.NotContains(t, result.BySerialNo, "S350")
+
+	assert.Equal(t, []string{"O-missing"}, result.MissingOrderNos)
+	assert.Len(t, result.ByOrderNo["O-a"], 1)
+	assert.Len(t, result.ByOrderNo["O1"], 2)
+}
+
+func TestChunkStrings(t *testing.T) {
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, chunkStrings([]string{"a", "b", "c"}, 2))
+	assert.Empty(t, chunkStrings(nil, 2))
+	assert.Equal(t, []string{"a", "b"}, uniqueStrings([]string{"a", "", "b", "a"}))
+}
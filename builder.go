@@ -0,0 +1,215 @@
+package nuonuo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const invoiceTypeBlue = "1" // 开票类型：1 蓝票 2 红票
+
+var ErrIncompleteInvoice = errors.New("incomplete invoice")
+
+// SellerProfile 销方开票默认信息
+type SellerProfile struct {
+	SalerTaxNum     string
+	SalerTel        string
+	SalerAddress    string
+	SalerAccount    string
+	Clerk           string // 开票员
+	Payee           string // 收款人
+	Checker         string // 复核人
+	DepartmentID    string
+	ClerkID         string
+	ExtensionNumber string
+}
+
+// InvoiceBuilder 链式构建开票订单
+//
+//	order, err := NewInvoice(profile).
+//		OrderNo("202401010001").
+//		Buyer("购方名称", "91330106MA2XXXXXXX").
+//		Line(&GoodsItem{GoodsName: "服务费", TaxRate: "0.06", Price: "100", Num: "1"}).
+//		Electronic().
+//		Build()
+type InvoiceBuilder struct {
+	order *InvoiceOrder
+	now   func() time.Time
+	errs  []error
+}
+
+func NewInvoice(profile *SellerProfile) *InvoiceBuilder {
+	return &InvoiceBuilder{
+		order: &InvoiceOrder{
+			SalerTaxNum:     profile.SalerTaxNum,
+			SalerTel:        profile.SalerTel,
+			SalerAddress:    profile.SalerAddress,
+			SalerAccount:    profile.SalerAccount,
+			Clerk:           profile.Clerk,
+			Payee:           profile.Payee,
+			Checker:         profile.Checker,
+			DepartmentID:    profile.DepartmentID,
+			ClerkID:         profile.ClerkID,
+			ExtensionNumber: profile.ExtensionNumber,
+			InvoiceType:     invoiceTypeBlue,
+		},
+		now: time.Now,
+	}
+}
+
+// Clock 设置取当前时间的函数，默认 time.Now
+func (b *InvoiceBuilder) Clock(now func() time.Time) *InvoiceBuilder {
+	b.now = now
+	return b
+}
+
+func (b *InvoiceBuilder) OrderNo(orderNo string) *InvoiceBuilder {
+	b.order.OrderNo = orderNo
+	return b
+}
+
+// Buyer 设置购方名称与税号，个人购方税号可为空
+func (b *InvoiceBuilder) Buyer(name, taxNum string) *InvoiceBuilder {
+	b.order.BuyerName = name
+	b.order.BuyerTaxNum = taxNum
+
+	return b
+}
+
+// BuyerContact 设置购方地址、电话与开户行及账号
+func (b *InvoiceBuilder) BuyerContact(address, tel, account string) *InvoiceBuilder {
+	b.order.BuyerAddress = address
+	b.order.BuyerTel = tel
+	b.order.BuyerAccount = account
+
+	return b
+}
+
+// BuyerTitle 使用企业抬头查询结果设置购方信息
+func (b *InvoiceBuilder) BuyerTitle(t *EnterpriseTitle) *InvoiceBuilder {
+	t.ApplyTo(b.order)
+	return b
+}
+
+// Line 添加明细行，未填写的金额与税额在 Build 时计算
+func (b *InvoiceBuilder) Line(item *GoodsItem) *InvoiceBuilder {
+	line := *item
+	b.order.InvoiceDetail = append(b.order.InvoiceDetail, &line)
+
+	return b
+}
+
+// LineWithPolicy 添加适用税收政策的明细行
+func (b *InvoiceBuilder) LineWithPolicy(item *GoodsItem, policy TaxPolicy) *InvoiceBuilder {
+	line := *item
+	if err := ApplyTaxPolicy(&line, policy); err != nil {
+		b.errs = append(b.errs, err)
+	}
+
+	b.order.InvoiceDetail = append(b.order.InvoiceDetail, &line)
+
+	return b
+}
+
+// InvoiceLine 设置发票种类
+func (b *InvoiceBuilder) InvoiceLine(line string) *InvoiceBuilder {
+	b.order.InvoiceLine = line
+	return b
+}
+
+// Electronic 开具数电普票
+func (b *InvoiceBuilder) Electronic() *InvoiceBuilder {
+	return b.InvoiceLine(InvoiceLineAllElectronicNormal)
+}
+
+// ElectronicSpecial 开具数电专票
+func (b *InvoiceBuilder) ElectronicSpecial() *InvoiceBuilder {
+	return b.InvoiceLine(InvoiceLineAllElectronicSpecial)
+}
+
+func (b *InvoiceBuilder) Remark(remark string) *InvoiceBuilder {
+	b.order.Remark = remark
+	return b
+}
+
+// Notify 设置开票后的交付方式
+func (b *InvoiceBuilder) Notify(mode PushMode, email, phone string) *InvoiceBuilder {
+	b.order.PushMode = string(mode)
+	b.order.Email = email
+	b.order.BuyerPhone = phone
+
+	return b
+}
+
+func (b *InvoiceBuilder) CallBackURL(url string) *InvoiceBuilder {
+	b.order.CallBackURL = url
+	return b
+}
+
+// Apply 对订单做其他修改，如设置特定要素
+func (b *InvoiceBuilder) Apply(fn func(order *InvoiceOrder) error) *InvoiceBuilder {
+	if err := fn(b.order); err != nil {
+		b.errs = append(b.errs, err)
+	}
+
+	return b
+}
+
+// Build 计算金额、填写开票日期并校验订单，返回的订单与构建器共用，Build 后不应继续修改构建器
+func (b *InvoiceBuilder) Build() (*InvoiceOrder, error) {
+	if len(b.errs) > 0 {
+		return nil, errors.Join(b.errs...)
+	}
+
+	order := b.order
+
+	missing := []string{}
+	for _, f := range []requiredField{
+		{"orderNo", order.OrderNo},
+		{"buyerName", order.BuyerName},
+		{"salerTaxNum", order.SalerTaxNum},
+		{"clerk", order.Clerk},
+		{"invoiceLine", order.InvoiceLine},
+	} {
+		if strings.TrimSpace(f.value) == "" {
+			missing = append(missing, f.name)
+		}
+	}
+
+	if len(order.InvoiceDetail) == 0 {
+		missing = append(missing, "invoiceDetail")
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: missing %s", ErrIncompleteInvoice, strings.Join(missing, ", "))
+	}
+
+	for i, item := range order.InvoiceDetail {
+		if item.WithTaxFlag == "" {
+			item.WithTaxFlag = "1"
+		}
+
+		excluded, included, tax, err := lineAmounts(item)
+		if err != nil {
+			return nil, &LineError{Index: i, Err: err}
+		}
+
+		item.TaxExcludedAmount = formatAmount(excluded)
+		item.TaxIncludedAmount = formatAmount(included)
+		item.Tax = formatAmount(tax)
+	}
+
+	if !isAllElectronicLine(order.InvoiceLine) && len(order.InvoiceDetail) > DefaultInvoiceLimits.MaxLines {
+		order.ListFlag = "1"
+		order.ListName = defaultListName
+	}
+
+	order.SetInvoiceDate(b.now())
+
+	if err := order.Validate(); err != nil {
+		return nil, fmt.Errorf("validate invoice: %w", err)
+	}
+
+	return order, nil
+}
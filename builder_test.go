@@ -0,0 +1,46 @@
+package nuonuo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoiceBuilder(t *testing.T) {
+	profile := &SellerProfile{SalerTaxNum: "339901999999199", Clerk: "张三"}
+	now := time.Date(2024, 1, 2, 1, 3, 4, 0, time.UTC)
+
+	item := &GoodsItem{GoodsName: "服务费", TaxRate: "0.06", Price: "53", Num: "2"}
+	order, err := NewInvoice(profile).
+		Clock(func() time.Time { return now }).
+		OrderNo("202401020001").
+		Buyer("购方名称", "").
+		Line(item).
+		Electronic().
+		Build()
+	require.NoError(t, err)
+
+	assert.Equal(t, "2024-01-02 09:03:04", order.InvoiceDate)
+	assert.Equal(t, "1", order.InvoiceType)
+	assert.Equal(t, InvoiceLineAllElectronicNormal, order.InvoiceLine)
+	assert.Equal(t, "339901999999199", order.SalerTaxNum)
+
+	line := order.InvoiceDetail[0]
+	assert.Equal(t, "106.00", line.TaxIncludedAmount)
+	assert.Equal(t, "100.00", line.TaxExcludedAmount)
+	assert.Equal(t, "6.00", line.Tax)
+	assert.Empty(t, item.Tax, "caller's item is not modified")
+
+	_, err = NewInvoice(profile).Line(item).Build()
+	assert.ErrorIs(t, err, ErrIncompleteInvoice)
+
+	_, err = NewInvoice(profile).
+		OrderNo("202401020002").
+		Buyer("购方名称", "").
+		LineWithPolicy(&GoodsItem{GoodsName: "服务费", TaxRate: "0.06", Price: "1", Num: "1"}, TaxPolicy(99)).
+		Electronic().
+		Build()
+	assert.ErrorIs(t, err, ErrInvalidTaxPolicy)
+}
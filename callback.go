@@ -0,0 +1,319 @@
+package nuonuo
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	callbackMaxBody   = 1 << 20
+	defaultClaimLease = 5 * time.Minute
+)
+
+// InvoiceEvent 开票结果推送
+type InvoiceEvent struct {
+	SerialNo                  string
+	OrderNo                   string
+	Status                    InvoiceStatus
+	StatusMsg                 string
+	InvoiceCode               string
+	InvoiceNo                 string
+	AllElectronicInvoiceNumbe string
+	InvoiceTime               int64 // 开票时间（毫秒时间戳）
+	ExTaxAmount               string
+	TaxAmount                 string
+	OrderAmount               string
+	CheckCode                 string
+	BuyerName                 string
+	BuyerTaxNum               string
+	PdfURL                    string
+	PictureURL                string
+	OfdURL                    string
+
+	Raw json.RawMessage // 推送的原始内容
+}
+
+// callbackContent 回调推送 content 参数
+type callbackContent struct {
+	SerialNo    string `json:"c_fpqqlsh"`
+	OrderNo     string `json:"c_orderno"`
+	Status      string `json:"c_status"`
+	ResultMsg   string `json:"c_resultmsg"`
+	InvoiceCode string `json:"c_fpdm"`
+	InvoiceNo   string `json:"c_fphm"`
+	ElecNo      string `json:"c_qdfphm"`
+	InvoiceTime int64  `json:"c_kprq"`
+	ExTaxAmount string `json:"c_bhsje"`
+	TaxAmount   string `json:"c_hjse"`
+	OrderAmount string `json:"c_jshj"`
+	CheckCode   string `json:"c_jym"`
+	BuyerName   string `json:"c_buyername"`
+	BuyerTaxNum string `json:"c_taxnum"`
+	PdfURL      string `json:"c_url"`
+	PictureURL  string `json:"c_jpg_url"`
+	OfdURL      string `json:"c_ofd_url"`
+}
+
+// ParseInvoiceEvent 解析回调推送的 content 内容
+func ParseInvoiceEvent(content []byte) (*InvoiceEvent, error) {
+	var c callbackContent
+	if err := json.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("parse callback content: %w", err)
+	}
+
+	if c.SerialNo == "" {
+		return nil, errors.New("callback content without serial no")
+	}
+
+	return &InvoiceEvent{
+		SerialNo:                  c.SerialNo,
+		OrderNo:                   c.OrderNo,
+		Status:                    InvoiceStatus(c.Status),
+		StatusMsg:                 c.ResultMsg,
+		InvoiceCode:               c.InvoiceCode,
+		InvoiceNo:                 c.InvoiceNo,
+		AllElectronicInvoiceNumbe: c.ElecNo,
+		InvoiceTime:               c.InvoiceTime,
+		ExTaxAmount:               c.ExTaxAmount,
+		TaxAmount:                 c.TaxAmount,
+		OrderAmount:               c.OrderAmount,
+		CheckCode:                 c.CheckCode,
+		BuyerName:                 c.BuyerName,
+		BuyerTaxNum:               c.BuyerTaxNum,
+		PdfURL:                    c.PdfURL,
+		PictureURL:                c.PictureURL,
+		OfdURL:                    c.OfdURL,
+		Raw:                       json.RawMessage(content),
+	}, nil
+}
+
+// InvoiceEventHandler 处理开票结果推送，返回错误时平台会重新推送
+type InvoiceEventHandler func(ctx context.Context, event *InvoiceEvent) error
+
+// DedupStore 记录已处理的推送，用于推送去重
+type DedupStore interface {
+	// Claim 原子地占用 key 用于处理推送，占用 lease 后过期；
+	// key 已处理或正被占用时返回 false
+	Claim(ctx context.Context, key string, lease time.Duration) (bool, error)
+	// Mark 标记 key 已处理
+	Mark(ctx context.Context, key string) error
+	// Release 释放占用，推送处理失败时调用，平台重新推送时可再次处理
+	Release(ctx context.Context, key string) error
+}
+
+type memoryDedupStore struct {
+	ttl  time.Duration
+	mu   sync.Mutex
+	keys map[string]time.Time // key -> 过期时间
+}
+
+// NewMemoryDedupStore 基于内存的去重存储，已处理的记录在 ttl 后过期
+func NewMemoryDedupStore(ttl time.Duration) DedupStore {
+	return &memoryDedupStore{
+		ttl:  ttl,
+		keys: map[string]time.Time{},
+	}
+}
+
+func (s *memoryDedupStore) Claim(ctx context.Context, key string, lease time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, expires := range s.keys {
+		if now.After(expires) {
+			delete(s.keys, k)
+		}
+	}
+
+	if _, ok := s.keys[key]; ok {
+		return false, nil
+	}
+
+	s.keys[key] = now.Add(lease)
+
+	return true, nil
+}
+
+func (s *memoryDedupStore) Mark(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key] = time.Now().Add(s.ttl)
+
+	return nil
+}
+
+func (s *memoryDedupStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, key)
+
+	return nil
+}
+
+// CallbackHandler 接收开票结果推送（callBackUrl）的 http.Handler。
+//
+// 平台推送不携带签名，可在 callBackUrl 中附带密钥参数并通过 Verify 校验，
+// 见 TokenVerifier。
+type CallbackHandler struct {
+	Verify func(r *http.Request) error // 校验推送来源，为空时不校验
+	Dedup  DedupStore                  // 推送去重，为空时不去重
+
+	// 处理推送时占用去重记录的时长，默认5分钟。
+	// 处理中进程中断时，占用过期后平台重新推送的内容可再次处理。
+	ClaimLease time.Duration
+
+	mu       sync.RWMutex
+	handlers []InvoiceEventHandler
+}
+
+func NewCallbackHandler() *CallbackHandler {
+	return &CallbackHandler{}
+}
+
+// OnInvoice 注册推送处理函数，按注册顺序调用
+func (h *CallbackHandler) OnInvoice(fn InvoiceEventHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.handlers = append(h.handlers, fn)
+}
+
+type callbackAck struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.Verify != nil {
+		if err := h.Verify(r); err != nil {
+			writeCallbackAck(w, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	content, err := readCallbackContent(r)
+	if err != nil {
+		writeCallbackAck(w, http.StatusBadRequest, err)
+		return
+	}
+
+	event, err := ParseInvoiceEvent(content)
+	if err != nil {
+		writeCallbackAck(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.dispatch(r.Context(), event); err != nil {
+		writeCallbackAck(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeCallbackAck(w, http.StatusOK, nil)
+}
+
+// dispatch 调用处理函数前先占用推送，同一推送并发到达时只处理一次。
+// 处理成功后标记为已处理，处理失败时释放占用。
+func (h *CallbackHandler) dispatch(ctx context.Context, event *InvoiceEvent) error {
+	key := event.SerialNo + ":" + string(event.Status)
+
+	if h.Dedup != nil {
+		lease := h.ClaimLease
+		if lease <= 0 {
+			lease = defaultClaimLease
+		}
+
+		claimed, err := h.Dedup.Claim(ctx, key, lease)
+		if err != nil {
+			return err
+		}
+
+		if !claimed {
+			return nil
+		}
+	}
+
+	h.mu.RLock()
+	handlers := h.handlers
+	h.mu.RUnlock()
+
+	for _, fn := range handlers {
+		if err := fn(ctx, event); err != nil {
+			if h.Dedup != nil {
+				return errors.Join(err, h.Dedup.Release(context.WithoutCancel(ctx), key))
+			}
+
+			return err
+		}
+	}
+
+	if h.Dedup != nil {
+		return h.Dedup.Mark(ctx, key)
+	}
+
+	return nil
+}
+
+// readCallbackContent 读取推送内容，支持表单参数 content 与 JSON 请求体
+func readCallbackContent(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, callbackMaxBody))
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+
+		content := form.Get("content")
+		if content == "" {
+			return nil, errors.New("missing content")
+		}
+
+		return []byte(content), nil
+	}
+
+	return body, nil
+}
+
+func writeCallbackAck(w http.ResponseWriter, code int, err error) {
+	ack := callbackAck{Status: "0000", Message: "成功"}
+	if err != nil {
+		ack = callbackAck{Status: "9999", Message: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/json;charset=UTF-8")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(ack)
+}
+
+// TokenVerifier 校验 callBackUrl 中附带的密钥参数
+func TokenVerifier(param, token string) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		got := r.URL.Query().Get(param)
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return errors.New("invalid callback token")
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,172 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	h := NewCallbackHandler()
+	h.Verify = TokenVerifier("token", "secret")
+	h.Dedup = NewMemoryDedupStore(time.Hour)
+
+	events := []*InvoiceEvent{}
+	h.OnInvoice(func(ctx context.Context, event *InvoiceEvent) error {
+		events = append(events, event)
+		return nil
+	})
+
+	form := url.Values{
+		"operater": {"callback"},
+		"content":  {`{"c_fpqqlsh":"S1","c_orderno":"O1","c_status":"2","c_kprq":1700000000000}`},
+	}
+
+	push := func(target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := push("/callback?token=secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"0000"`)
+
+	w = push("/callback?token=secret")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = push("/callback?token=wrong")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "S1", events[0].SerialNo)
+		assert.Equal(t, "O1", events[0].OrderNo)
+		assert.True(t, events[0].Status.IsSuccess())
+		assert.Equal(t, int64(1700000000000), events[0].InvoiceTime)
+	}
+}
+
+func TestCallbackHandler_DedupConcurrent(t *testing.T) {
+	h := NewCallbackHandler()
+	h.Dedup = NewMemoryDedupStore(time.Hour)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	h.OnInvoice(func(ctx context.Context, event *InvoiceEvent) error {
+		calls.Add(1)
+		<-release
+		return nil
+	})
+
+	event := &InvoiceEvent{SerialNo: "S1", Status: InvoiceStatusCompleted}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, h.dispatch(context.Background(), event))
+		}()
+	}
+
+	// 处理中的推送再次到达时不重复处理
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestCallbackHandler_DedupReleaseOnError(t *testing.T) {
+	h := NewCallbackHandler()
+	h.Dedup = NewMemoryDedupStore(time.Hour)
+
+	calls := 0
+	h.OnInvoice(func(ctx context.Context, event *InvoiceEvent) error {
+		calls++
+		if calls == 1 {
+			return errors.New("handler failed")
+		}
+
+		return nil
+	})
+
+	event := &InvoiceEvent{SerialNo: "S1", Status: InvoiceStatusCompleted}
+
+	// 处理失败后移除标记，重新推送时再次处理
+	assert.Error(t, h.dispatch(context.Background(), event))
+	assert.NoError(t, h.dispatch(context.Background(), event))
+	assert.NoError(t, h.dispatch(context.Background(), event))
+	assert.Equal(t, 2, calls)
+}
+
+func TestMemoryDedupStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryDedupStore(time.Hour)
+
+	claimed, err := s.Claim(ctx, "K", time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, _ = s.Claim(ctx, "K", time.Hour)
+	assert.False(t, claimed)
+
+	// 释放后可再次占用
+	assert.NoError(t, s.Release(ctx, "K"))
+	claimed, _ = s.Claim(ctx, "K", 10*time.Millisecond)
+	assert.True(t, claimed)
+
+	// 处理中断时占用过期后可再次占用
+	time.Sleep(20 * time.Millisecond)
+	claimed, _ = s.Claim(ctx, "K", 10*time.Millisecond)
+	assert.True(t, claimed)
+
+	// 已处理的记录按 ttl 保留
+	assert.NoError(t, s.Mark(ctx, "K"))
+	time.Sleep(20 * time.Millisecond)
+	claimed, _ = s.Claim(ctx, "K", time.Hour)
+	assert.False(t, claimed)
+}
+
+func TestCallbackHandler_DedupCrashRecovery(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryDedupStore(time.Hour)
+
+	// 模拟处理中进程中断：推送已占用但未标记为已处理
+	claimed, err := store.Claim(ctx, "S1:2", 10*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	h := NewCallbackHandler()
+	h.Dedup = store
+
+	calls := 0
+	h.OnInvoice(func(ctx context.Context, event *InvoiceEvent) error {
+		calls++
+		return nil
+	})
+
+	event := &InvoiceEvent{SerialNo: "S1", Status: InvoiceStatusCompleted}
+
+	require.NoError(t, h.dispatch(ctx, event))
+	assert.Zero(t, calls)
+
+	// 占用过期后重新推送的内容再次处理
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, h.dispatch(ctx, event))
+	require.NoError(t, h.dispatch(ctx, event))
+	assert.Equal(t, 1, calls)
+}
@@ -10,6 +10,7 @@ import (
 	"math/rand"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-resty/resty/v2"
@@ -22,20 +23,27 @@ type Client struct {
 	appSecret string
 	userTax   string
 
-	tc          TokenController
-	restyClient *resty.Client
-	rand        *rand.Rand
+	tc             TokenController
+	restyClient    *resty.Client
+	downloadClient *resty.Client
+	randMu         sync.Mutex // rand 不支持并发使用
+	rand           *rand.Rand
+
+	quotaGuard    *quotaGuard
+	decodeWarning func(ctx context.Context, warning *DecodeError)
+	onResponse    func(ctx context.Context, meta *ResponseMeta)
 }
 
 func New(url, appKey, appSecret, userTax string, tc TokenController) *Client {
 	return &Client{
-		url:         url,
-		appKey:      appKey,
-		appSecret:   appSecret,
-		userTax:     userTax,
-		tc:          tc,
-		restyClient: resty.New().SetTimeout(5 * time.Second),
-		rand:        rand.New(rand.NewSource(time.Now().UnixNano())), // nolint: gosec
+		url:            url,
+		appKey:         appKey,
+		appSecret:      appSecret,
+		userTax:        userTax,
+		tc:             tc,
+		restyClient:    resty.New().SetTimeout(5 * time.Second),
+		downloadClient: newDownloadClient(),
+		rand:           rand.New(rand.NewSource(time.Now().UnixNano())), // nolint: gosec
 	}
 }
 
@@ -96,6 +104,11 @@ type (
 		AdditionalElementList []*AdditionalElement `json:"additionalElementList,omitempty"`
 
 		InvoiceTravellerTransportInfoList []*TravellerTransportItem `json:"invoiceTravellerTransportInfoList,omitempty"`
+
+		InvoiceBuildingInfo    *ConstructionInfo     `json:"invoiceBuildingInfo,omitempty"`    // 建筑服务
+		InvoiceGoodsTransports []*GoodsTransportItem `json:"invoiceGoodsTransports,omitempty"` // 货物运输服务
+		RealPropertySellInfo   *RealEstateSaleInfo   `json:"realPropertySellInfo,omitempty"`   // 不动产销售
+		RealPropertyRentInfo   *RealEstateLeaseInfo  `json:"realPropertyRentInfo,omitempty"`   // 不动产经营租赁
 	}
 
 	GoodsItem struct {
@@ -144,10 +157,31 @@ type (
 func (c *Client) OpenInvoice(
 	ctx context.Context, req *OpenInvoiceRequest,
 ) (*OpenInvoiceResponse, error) {
+	if req.Order != nil {
+		if err := req.Order.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var reservation *quotaReservation
+
+	if c.quotaGuard != nil {
+		var err error
+
+		reservation, err = c.quotaGuard.reserve(ctx, c, req.Order)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	resp := &OpenInvoiceResponse{}
 
 	err := c.request(ctx, "nuonuo.OpeMplatform.requestBillingNew", req, resp)
 	if err != nil {
+		if reservation != nil {
+			c.quotaGuard.release(reservation)
+		}
+
 		return nil, err
 	}
 
@@ -162,69 +196,70 @@ type (
 	}
 
 	InvoiceResultItem struct {
-		SerialNo                  string `json:"serialNo"`
-		OrderNo                   string `json:"orderNo"`
-		Status                    string `json:"status"`
-		StatusMsg                 string `json:"statusMsg"`
-		FailCause                 string `json:"failCause"`
-		PdfURL                    string `json:"pdfUrl"`
-		PictureURL                string `json:"pictureUrl"`
-		InvoiceTime               int64  `json:"invoiceTime"`
-		InvoiceCode               string `json:"invoiceCode"`
-		InvoiceNo                 string `json:"invoiceNo"`
-		AllElectronicInvoiceNumbe string `json:"allElectronicInvoiceNumbe"`
-		ExTaxAmount               string `json:"exTaxAmount"`
-		TaxAmount                 string `json:"taxAmount"`
-		OrderAmount               string `json:"orderAmount"`
-		PayerName                 string `json:"payerName"`
-		PayerTaxNo                string `json:"payerTaxNo"`
-		Address                   string `json:"address"`
-		Telephone                 string `json:"telephone"`
-		BankAccount               string `json:"bankAccount"`
-		InvoiceKind               string `json:"invoiceKind"`
-		CheckCode                 string `json:"checkCode"`
-		QrCode                    string `json:"qrCode"`
-		MachineCode               string `json:"machineCode"`
-		CipherText                string `json:"cipherText"`
-		PaperPdfURL               string `json:"paperPdfUrl"`
-		OfdURL                    string `json:"ofdUrl"`
-		Clerk                     string `json:"clerk"`
-		Payee                     string `json:"payee"`
-		Checker                   string `json:"checker"`
-		SalerAccount              string `json:"salerAccount"`
-		SalerTel                  string `json:"salerTel"`
-		SalerAddress              string `json:"salerAddress"`
-		SalerTaxNum               string `json:"salerTaxNum"`
-		SaleName                  string `json:"saleName"`
-		Remark                    string `json:"remark"`
-		ProductOilFlag            int    `json:"productOilFlag"`
-		ImgURLs                   string `json:"imgUrls"`
-		ExtensionNumber           string `json:"extensionNumber"`
-		TerminalNumber            string `json:"terminalNumber"`
-		DeptID                    string `json:"deptId"`
-		ClerkID                   string `json:"clerkId"`
-		OldInvoiceCode            string `json:"oldInvoiceCode"`
-		OldInvoiceNo              string `json:"oldInvoiceNo"`
-		OldEleInvoiceNumber       string `json:"oldEleInvoiceNumber"`
-		ListFlag                  string `json:"listFlag"`
-		ListName                  string `json:"listName"`
-		Phone                     string `json:"phone"`
-		NotifyEmail               string `json:"notifyEmail"`
-		VehicleFlag               string `json:"vehicleFlag"`
-		CreateTime                int64  `json:"createTime"`
-		UpdateTime                int64  `json:"updateTime"`
-		ProxyInvoiceFlag          string `json:"proxyInvoiceFlag"`
-		InvoiceDate               int64  `json:"invoiceDate"`
-		InvoiceType               string `json:"invoiceType"`
-		RedReason                 string `json:"redReason"`
-		InvalidTime               string `json:"invalidTime"`
-		InvalidSource             string `json:"invalidSource"`
-		InvalidReason             string `json:"invalidReason"`
-		SpecificReason            string `json:"specificReason"`
-		SpecificFactor            int    `json:"specificFactor"`
-		BuyerManagerName          string `json:"buyerManagerName"`
-		ManagerCardType           string `json:"managerCardType"`
-		ManagerCardNo             string `json:"managerCardNo"`
+		SerialNo                  string        `json:"serialNo"`
+		OrderNo                   string        `json:"orderNo"`
+		Status                    InvoiceStatus `json:"status"`
+		StatusMsg                 string        `json:"statusMsg"`
+		FailCause                 string        `json:"failCause"`
+		PdfURL                    string        `json:"pdfUrl"`
+		PictureURL                string        `json:"pictureUrl"`
+		InvoiceTime               int64         `json:"invoiceTime"`
+		InvoiceCode               string        `json:"invoiceCode"`
+		InvoiceNo                 string        `json:"invoiceNo"`
+		AllElectronicInvoiceNumbe string        `json:"allElectronicInvoiceNumbe"`
+		ExTaxAmount               string        `json:"exTaxAmount"`
+		TaxAmount                 string        `json:"taxAmount"`
+		OrderAmount               string        `json:"orderAmount"`
+		PayerName                 string        `json:"payerName"`
+		PayerTaxNo                string        `json:"payerTaxNo"`
+		Address                   string        `json:"address"`
+		Telephone                 string        `json:"telephone"`
+		BankAccount               string        `json:"bankAccount"`
+		InvoiceKind               string        `json:"invoiceKind"`
+		CheckCode                 string        `json:"checkCode"`
+		QrCode                    string        `json:"qrCode"`
+		MachineCode               string        `json:"machineCode"`
+		CipherText                string        `json:"cipherText"`
+		PaperPdfURL               string        `json:"paperPdfUrl"`
+		OfdURL                    string        `json:"ofdUrl"`
+		XMLURL                    string        `json:"xmlUrl"`
+		Clerk                     string        `json:"clerk"`
+		Payee                     string        `json:"payee"`
+		Checker                   string        `json:"checker"`
+		SalerAccount              string        `json:"salerAccount"`
+		SalerTel                  string        `json:"salerTel"`
+		SalerAddress              string        `json:"salerAddress"`
+		SalerTaxNum               string        `json:"salerTaxNum"`
+		SaleName                  string        `json:"saleName"`
+		Remark                    string        `json:"remark"`
+		ProductOilFlag            int           `json:"productOilFlag"`
+		ImgURLs                   string        `json:"imgUrls"`
+		ExtensionNumber           string        `json:"extensionNumber"`
+		TerminalNumber            string        `json:"terminalNumber"`
+		DeptID                    string        `json:"deptId"`
+		ClerkID                   string        `json:"clerkId"`
+		OldInvoiceCode            string        `json:"oldInvoiceCode"`
+		OldInvoiceNo              string        `json:"oldInvoiceNo"`
+		OldEleInvoiceNumber       string        `json:"oldEleInvoiceNumber"`
+		ListFlag                  string        `json:"listFlag"`
+		ListName                  string        `json:"listName"`
+		Phone                     string        `json:"phone"`
+		NotifyEmail               string        `json:"notifyEmail"`
+		VehicleFlag               string        `json:"vehicleFlag"`
+		CreateTime                int64         `json:"createTime"`
+		UpdateTime                int64         `json:"updateTime"`
+		ProxyInvoiceFlag          string        `json:"proxyInvoiceFlag"`
+		InvoiceDate               int64         `json:"invoiceDate"`
+		InvoiceType               string        `json:"invoiceType"`
+		RedReason                 string        `json:"redReason"`
+		InvalidTime               string        `json:"invalidTime"`
+		InvalidSource             string        `json:"invalidSource"`
+		InvalidReason             string        `json:"invalidReason"`
+		SpecificReason            string        `json:"specificReason"`
+		SpecificFactor            int           `json:"specificFactor"`
+		BuyerManagerName          string        `json:"buyerManagerName"`
+		ManagerCardType           string        `json:"managerCardType"`
+		ManagerCardNo             string        `json:"managerCardNo"`
 	}
 )
 
@@ -308,6 +343,22 @@ type (
 		ExtensionNumber string `json:"extensionNumber,omitempty"` // 分机号
 
 		OrderNo string `json:"orderNo,omitempty"` // 订单号
+
+		Detail []*RedConfirmDetail `json:"detail,omitempty"` // 部分冲红明细，不传则全额冲红
+	}
+
+	RedConfirmDetail struct {
+		BlueDetailIndex   string `json:"blueDetailIndex"`             // 对应蓝票明细行序号，从1开始
+		GoodsName         string `json:"goodsName,omitempty"`         // 商品名称
+		GoodsCode         string `json:"goodsCode,omitempty"`         // 税收分类编码
+		SpecType          string `json:"specType,omitempty"`          // 规格型号
+		Unit              string `json:"unit,omitempty"`              // 单位
+		Num               string `json:"num,omitempty"`               // 数量（负数）
+		Price             string `json:"price,omitempty"`             // 单价
+		TaxRate           string `json:"taxRate"`                     // 税率
+		TaxExcludedAmount string `json:"taxExcludedAmount"`           // 不含税金额（负数）
+		Tax               string `json:"tax"`                         // 税额（负数）
+		TaxIncludedAmount string `json:"taxIncludedAmount,omitempty"` // 含税金额（负数）
 	}
 
 	SaveInvoiceRedConfirmResponse struct {
@@ -417,6 +468,211 @@ func (c *Client) ConfirmRedInvoice(
 	return resp, nil
 }
 
+type DeliverInvoiceRequest struct {
+	TaxNum      string `json:"taxnum"`                // 销方税号
+	InvoiceCode string `json:"invoiceCode,omitempty"` // 发票代码，数电发票不传
+	InvoiceNum  string `json:"invoiceNum"`            // 发票号码，数电发票传20位数电票号码
+	Phone       string `json:"phone,omitempty"`       // 交付手机号
+	Mail        string `json:"mail,omitempty"`        // 交付邮箱
+}
+
+// 诺税通saas发票重新交付接口。将已开具的发票重新发送到指定邮箱或手机。
+func (c *Client) DeliverInvoice(ctx context.Context, req *DeliverInvoiceRequest) error {
+	return c.request(ctx, "nuonuo.OpeMplatform.deliveryInvoice", req, nil)
+}
+
+type (
+	InvalidateInvoiceRequest struct {
+		InvoiceID   string `json:"invoiceId,omitempty"`   // 发票流水号，与发票代码、号码二选一
+		InvoiceCode string `json:"invoiceCode,omitempty"` // 发票代码
+		InvoiceNo   string `json:"invoiceNo,omitempty"`   // 发票号码
+	}
+
+	InvalidateInvoiceResponse struct {
+		InvoiceID string `json:"invoiceId"` // 发票流水号
+	}
+)
+
+// 诺税通saas发票作废接口。仅支持当月开具的纸质发票。
+func (c *Client) InvalidateInvoice(
+	ctx context.Context, req *InvalidateInvoiceRequest,
+) (*InvalidateInvoiceResponse, error) {
+	resp := &InvalidateInvoiceResponse{}
+
+	err := c.request(ctx, "nuonuo.OpeMplatform.invoiceCancellation", req, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+type PrintInvoiceRequest struct {
+	TaxNum          string `json:"taxnum"`                    // 销方税号
+	InvoiceID       string `json:"invoiceId,omitempty"`       // 发票流水号，与发票代码、号码二选一
+	InvoiceCode     string `json:"invoiceCode,omitempty"`     // 发票代码
+	InvoiceNo       string `json:"invoiceNo,omitempty"`       // 发票号码
+	PrintType       string `json:"printType,omitempty"`       // 打印类型：0 发票 1 销货清单
+	ExtensionNumber string `json:"extensionNumber,omitempty"` // 分机号
+	TerminalNumber  string `json:"terminalNumber,omitempty"`  // 终端号
+	MachineCode     string `json:"machineCode,omitempty"`     // 机器编号
+	OffsetX         string `json:"offsetX,omitempty"`         // 左偏移量（毫米）
+	OffsetY         string `json:"offsetY,omitempty"`         // 上偏移量（毫米）
+}
+
+// 诺税通saas发票打印接口。在税控终端上打印纸质发票。
+func (c *Client) PrintInvoice(ctx context.Context, req *PrintInvoiceRequest) error {
+	return c.request(ctx, "nuonuo.OpeMplatform.printInvoice", req, nil)
+}
+
+type (
+	QueryTerminalListRequest struct {
+		TaxNum          string `json:"taxnum"`                    // 销方税号
+		ExtensionNumber string `json:"extensionNumber,omitempty"` // 分机号
+		TerminalNumber  string `json:"terminalNumber,omitempty"`  // 终端号
+	}
+
+	TerminalItem struct {
+		TerminalNumber  string `json:"terminalNumber"`  // 终端号
+		TerminalName    string `json:"terminalName"`    // 终端名称
+		ExtensionNumber string `json:"extensionNumber"` // 分机号
+		MachineCode     string `json:"machineCode"`     // 机器编号
+		DeviceType      string `json:"deviceType"`      // 税控设备类型
+		OnlineStatus    string `json:"onlineStatus"`    // 在线状态：0 离线 1 在线
+		PrinterName     string `json:"printerName"`     // 打印机名称
+	}
+)
+
+// 诺税通saas税控终端列表查询接口
+func (c *Client) QueryTerminalList(
+	ctx context.Context, req *QueryTerminalListRequest,
+) ([]*TerminalItem, error) {
+	resp := []*TerminalItem{}
+
+	err := c.request(ctx, "nuonuo.OpeMplatform.queryTerminalList", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+type (
+	QueryInvoiceStockRequest struct {
+		TaxNum          string `json:"taxnum"`                    // 销方税号
+		ExtensionNumber string `json:"extensionNumber,omitempty"` // 分机号
+		MachineCode     string `json:"machineCode,omitempty"`     // 机器编号
+		InvoiceLine     string `json:"invoiceLine,omitempty"`     // 发票种类，不传查询全部
+	}
+
+	InvoiceStockItem struct {
+		ExtensionNumber string `json:"extensionNumber"` // 分机号
+		MachineCode     string `json:"machineCode"`     // 机器编号
+		InvoiceLine     string `json:"invoiceLine"`     // 发票种类
+		InvoiceCode     string `json:"invoiceCode"`     // 发票代码
+		InvoiceNumStart string `json:"invoiceNumStart"` // 起始号码
+		InvoiceNumEnd   string `json:"invoiceNumEnd"`   // 终止号码
+		RemainNum       int    `json:"remainNum"`       // 剩余份数
+	}
+)
+
+// 诺税通saas发票库存（余量）查询接口
+func (c *Client) QueryInvoiceStock(
+	ctx context.Context, req *QueryInvoiceStockRequest,
+) ([]*InvoiceStockItem, error) {
+	resp := []*InvoiceStockItem{}
+
+	err := c.request(ctx, "nuonuo.OpeMplatform.getInvoiceRepertoryInfo", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+type (
+	QueryCreditQuotaRequest struct {
+		TaxNum string `json:"taxnum"` // 销方税号
+	}
+
+	QueryCreditQuotaResponse struct {
+		TotalAmount  string `json:"totalAmount"`  // 授信总额度
+		UsedAmount   string `json:"usedAmount"`   // 已使用额度
+		RemainAmount string `json:"remainAmount"` // 剩余可用额度
+	}
+)
+
+// 诺税通saas数电发票授信额度查询接口
+func (c *Client) QueryCreditQuota(
+	ctx context.Context, req *QueryCreditQuotaRequest,
+) (*QueryCreditQuotaResponse, error) {
+	resp := &QueryCreditQuotaResponse{}
+
+	err := c.request(ctx, "nuonuo.OpeMplatform.queryCreditLimit", req, resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+type (
+	QueryEnterpriseTitleRequest struct {
+		Keyword string `json:"kpName"` // 企业名称关键字，按前缀及模糊匹配
+	}
+
+	EnterpriseTitle struct {
+		Name        string `json:"kpName"`      // 企业名称
+		TaxNum      string `json:"kpCode"`      // 纳税人识别号
+		Address     string `json:"kpAddr"`      // 地址
+		Tel         string `json:"kpTel"`       // 电话
+		Bank        string `json:"accountBank"` // 开户行
+		BankAccount string `json:"accountNo"`   // 银行账号
+	}
+)
+
+// 诺税通saas企业抬头查询接口。根据企业名称前缀或关键字查询企业开票信息。
+func (c *Client) QueryEnterpriseTitle(
+	ctx context.Context, req *QueryEnterpriseTitleRequest,
+) ([]*EnterpriseTitle, error) {
+	resp := []*EnterpriseTitle{}
+
+	err := c.request(ctx, "nuonuo.speedBilling.prefixQuery", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+type QueryTaxCodeRequest struct {
+	GoodsCode string `json:"goodsCode,omitempty"` // 税收分类编码
+	GoodsName string `json:"goodsName,omitempty"` // 商品名称关键字
+}
+
+// TaxCodeResult 税收分类编码查询结果，字段与官方编码表一致
+type TaxCodeResult struct {
+	Code        string `json:"bm"`      // 税收分类编码
+	Name        string `json:"mc"`      // 货物和劳务名称
+	ShortName   string `json:"spbmjc"`  // 商品和服务分类简称
+	Description string `json:"sm"`      // 说明
+	TaxRates    string `json:"zzssl"`   // 增值税税率，多个以顿号或逗号分隔，如 13%、9%
+	Policies    string `json:"zzstsgl"` // 增值税特殊管理，多个以顿号或逗号分隔
+	Status      string `json:"kyzt"`    // 可用状态：Y可用 N不可用
+}
+
+// 诺税通saas税收分类编码查询接口
+func (c *Client) QueryTaxCode(ctx context.Context, req *QueryTaxCodeRequest) ([]*TaxCodeResult, error) {
+	resp := []*TaxCodeResult{}
+
+	err := c.request(ctx, "nuonuo.OpeMplatform.queryTaxClassificationCode", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
 func (c *Client) sign(senid, nonce, timestamp, content string) (string, error) {
 	pairs := [][2]string{
 		{"a", "services"},
@@ -455,10 +711,12 @@ type requestCommon struct {
 }
 
 func (c *Client) newRequestCommon() *requestCommon {
+	c.randMu.Lock()
 	nonce := fmt.Sprintf("%08d", c.rand.Intn(100_000_000)) // nolint: gosec
 	if nonce[0] == '0' {
 		nonce = strconv.Itoa(c.rand.Intn(9)+1) + nonce[1:]
 	}
+	c.randMu.Unlock()
 
 	return &requestCommon{
 		senID:     strings.ReplaceAll(uuid.New().String(), "-", ""),
@@ -544,14 +802,27 @@ func (c *Client) post(
 		return fmt.Errorf("http status: %s, body: %s", resp.Status(), resp.Body())
 	}
 
+	c.recordResponse(ctx, &ResponseMeta{
+		Method:   method,
+		Code:     result.Code,
+		Describe: result.Describe,
+		Result:   result.Result,
+		List:     result.List,
+		Body:     resp.Body(),
+	})
+
 	if result.Code != "E0000" {
 		return &Error{Code: result.Code, Msg: result.Describe}
 	}
 
 	if resultPtr != nil {
-		err = json.Unmarshal(result.Result, resultPtr)
+		warning, err := decodeLenient(result.Result, resultPtr)
 		if err != nil {
-			return err
+			return &DecodeError{Method: method, Err: err}
+		}
+
+		if warning != nil {
+			c.warnDecode(ctx, method, warning)
 		}
 	}
 
@@ -0,0 +1,124 @@
+package nuonuo
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const deductionRemarkPrefix = "差额征税："
+
+var ErrInvalidDeduction = errors.New("invalid deduction")
+
+// ApplyDeduction 按差额征税开具：以含税金额减去扣除额的部分计算税额，
+// 并在备注前加上税务机关要求的差额征税说明。差额征税发票只能有一行明细。
+func ApplyDeduction(order *InvoiceOrder, deduction string) error {
+	if len(order.InvoiceDetail) != 1 {
+		return fmt.Errorf("%w: differential taxation requires exactly one line", ErrInvalidDeduction)
+	}
+
+	d, err := parseDecimal(deduction)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDeduction, err)
+	}
+
+	item := order.InvoiceDetail[0]
+	if item.WithTaxFlag == "0" {
+		return fmt.Errorf("%w: differential taxation requires tax-included price", ErrInvalidDeduction)
+	}
+
+	item.Deduction = formatAmount(d)
+	item.InvoiceLineProperty = "0"
+
+	// 以含税金额为准重新计算不含税金额与税额
+	item.Tax = ""
+	item.TaxExcludedAmount = ""
+
+	excluded, included, tax, err := lineAmounts(item)
+	if err != nil {
+		return err
+	}
+
+	item.TaxExcludedAmount = formatAmount(excluded)
+	item.TaxIncludedAmount = formatAmount(included)
+	item.Tax = formatAmount(tax)
+
+	if err := CheckDeduction(item); err != nil {
+		return err
+	}
+
+	order.Remark = deductionRemark(item.Deduction, order.Remark)
+
+	return nil
+}
+
+// CheckDeduction 校验差额征税明细行，未填写扣除额时不校验
+func CheckDeduction(item *GoodsItem) error {
+	if item.Deduction == "" {
+		return nil
+	}
+
+	d, err := parseDecimal(item.Deduction)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDeduction, err)
+	}
+
+	if d.Sign() <= 0 {
+		return fmt.Errorf("%w: deduction must be positive", ErrInvalidDeduction)
+	}
+
+	if item.InvoiceLineProperty != "" && item.InvoiceLineProperty != "0" {
+		return fmt.Errorf("%w: deduction line must be a normal line", ErrInvalidDeduction)
+	}
+
+	_, included, tax, err := lineAmounts(item)
+	if err != nil {
+		return err
+	}
+
+	if d.Cmp(included) >= 0 {
+		return fmt.Errorf(
+			"%w: deduction %s must be less than amount %s", ErrInvalidDeduction, item.Deduction, formatAmount(included),
+		)
+	}
+
+	rate, err := parseDecimal(item.TaxRate)
+	if err != nil {
+		return err
+	}
+
+	want := calcTax(included, nil, d, rate)
+	if diff := new(big.Rat).Sub(want, tax); diff.Abs(diff).Cmp(big.NewRat(1, 100)) > 0 {
+		return fmt.Errorf(
+			"%w: tax must be %s on amount after deduction, got %s", ErrInvalidDeduction, formatAmount(want), item.Tax,
+		)
+	}
+
+	return nil
+}
+
+// checkDeductionOrder 差额征税发票只能有一行明细
+func checkDeductionOrder(order *InvoiceOrder) error {
+	if len(order.InvoiceDetail) <= 1 {
+		return nil
+	}
+
+	for _, item := range order.InvoiceDetail {
+		if item.Deduction != "" {
+			return fmt.Errorf("%w: differential taxation requires exactly one line", ErrInvalidDeduction)
+		}
+	}
+
+	return nil
+}
+
+func deductionRemark(deduction, remark string) string {
+	if strings.HasPrefix(remark, deductionRemarkPrefix) {
+		if i := strings.Index(remark, "。"); i >= 0 {
+			remark = remark[i+len("。"):]
+		}
+	}
+
+	return deductionRemarkPrefix + deduction + "。" + remark
+}
@@ -0,0 +1,37 @@
+package nuonuo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyDeduction(t *testing.T) {
+	order := &InvoiceOrder{
+		Remark: "劳务派遣",
+		InvoiceDetail: []*GoodsItem{
+			{GoodsName: "劳务派遣服务", TaxRate: "0.05", WithTaxFlag: "1", TaxIncludedAmount: "10500"},
+		},
+	}
+
+	require.NoError(t, ApplyDeduction(order, "8400"))
+
+	item := order.InvoiceDetail[0]
+	assert.Equal(t, "8400.00", item.Deduction)
+	assert.Equal(t, "100.00", item.Tax)
+	assert.Equal(t, "10400.00", item.TaxExcludedAmount)
+	assert.Equal(t, "0", item.InvoiceLineProperty)
+	assert.Equal(t, "差额征税：8400.00。劳务派遣", order.Remark)
+	assert.NoError(t, order.Validate())
+
+	require.NoError(t, ApplyDeduction(order, "5250"))
+	assert.Equal(t, "差额征税：5250.00。劳务派遣", order.Remark)
+
+	item.Tax = "500.00"
+	assert.ErrorIs(t, order.Validate(), ErrInvalidDeduction)
+
+	order.InvoiceDetail = append(order.InvoiceDetail, &GoodsItem{TaxRate: "0.06", TaxIncludedAmount: "1"})
+	assert.ErrorIs(t, ApplyDeduction(order, "1"), ErrInvalidDeduction)
+	assert.ErrorIs(t, order.Validate(), ErrInvalidDeduction)
+}
@@ -0,0 +1,196 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// PushMode 发票交付方式
+type PushMode string
+
+const (
+	PushModeNone        PushMode = "-1" // 不推送
+	PushModeEmail       PushMode = "0"  // 邮箱
+	PushModeSMS         PushMode = "1"  // 手机（短信）
+	PushModeEmailAndSMS PushMode = "2"  // 邮箱与手机
+)
+
+func (m PushMode) email() bool {
+	return m == PushModeEmail || m == PushModeEmailAndSMS
+}
+
+func (m PushMode) sms() bool {
+	return m == PushModeSMS || m == PushModeEmailAndSMS
+}
+
+// Delivery 重新交付一张发票。
+// 可只提供流水号，发票代码与号码通过发票详情查询获取。
+type Delivery struct {
+	SerialNo    string
+	InvoiceCode string
+	InvoiceNum  string // 发票号码，数电发票为20位数电票号码
+
+	Mode  PushMode
+	Email string
+	Phone string
+}
+
+// DeliveryResult 重新交付结果，Err 为空表示平台已受理
+type DeliveryResult struct {
+	Delivery *Delivery
+	Err      error
+}
+
+func (r *DeliveryResult) Accepted() bool {
+	return r.Err == nil
+}
+
+// RedeliverInvoices 批量重新交付发票，单张失败不影响其他发票。
+func (c *Client) RedeliverInvoices(
+	ctx context.Context, taxNum string, deliveries []*Delivery, concurrency int,
+) []*DeliveryResult {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	results := make([]*DeliveryResult, len(deliveries))
+	for i, d := range deliveries {
+		results[i] = &DeliveryResult{Delivery: d}
+	}
+
+	items := c.resolveDeliveries(ctx, deliveries, results)
+
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, d := range deliveries {
+		if results[i].Err != nil {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i].Err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			req, err := deliverRequest(taxNum, d, items[d.SerialNo])
+			if err != nil {
+				results[i].Err = err
+				return
+			}
+
+			results[i].Err = c.DeliverInvoice(ctx, req)
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// resolveDeliveries 查询缺少发票号码的交付对应的发票
+func (c *Client) resolveDeliveries(
+	ctx context.Context, deliveries []*Delivery, results []*DeliveryResult,
+) map[string]*InvoiceResultItem {
+	serialNos := []string{}
+	for _, d := range deliveries {
+		if d.InvoiceNum == "" && d.SerialNo != "" {
+			serialNos = append(serialNos, d.SerialNo)
+		}
+	}
+
+	if len(serialNos) == 0 {
+		return nil
+	}
+
+	batch, err := c.QueryInvoicesBatch(ctx, &QueryInvoicesBatchRequest{SerialNos: serialNos})
+	if batch == nil {
+		for i, d := range deliveries {
+			if d.InvoiceNum == "" {
+				results[i].Err = err
+			}
+		}
+
+		return nil
+	}
+
+	// 查询失败的分片返回分片错误，而不是发票不存在
+	chunkErrs := map[string]error{}
+	for _, e := range batch.Errors {
+		for _, no := range e.SerialNos {
+			chunkErrs[no] = e
+		}
+	}
+
+	for i, d := range deliveries {
+		if d.InvoiceNum != "" || d.SerialNo == "" {
+			continue
+		}
+
+		if _, ok := batch.BySerialNo[d.SerialNo]; ok {
+			continue
+		}
+
+		if err, ok := chunkErrs[d.SerialNo]; ok {
+			results[i].Err = err
+		} else {
+			results[i].Err = fmt.Errorf("invoice %s not found", d.SerialNo)
+		}
+	}
+
+	return batch.BySerialNo
+}
+
+func deliverRequest(taxNum string, d *Delivery, item *InvoiceResultItem) (*DeliverInvoiceRequest, error) {
+	req := &DeliverInvoiceRequest{
+		TaxNum:      taxNum,
+		InvoiceCode: d.InvoiceCode,
+		InvoiceNum:  d.InvoiceNum,
+	}
+
+	if req.InvoiceNum == "" {
+		if item == nil {
+			return nil, errors.New("invoice number or serial no required")
+		}
+
+		if item.AllElectronicInvoiceNumbe != "" {
+			req.InvoiceNum = item.AllElectronicInvoiceNumbe
+		} else {
+			req.InvoiceCode = item.InvoiceCode
+			req.InvoiceNum = item.InvoiceNo
+		}
+	}
+
+	if d.Mode.email() {
+		if d.Email == "" {
+			return nil, errors.New("email required")
+		}
+
+		req.Mail = d.Email
+	}
+
+	if d.Mode.sms() {
+		if d.Phone == "" {
+			return nil, errors.New("phone required")
+		}
+
+		req.Phone = d.Phone
+	}
+
+	if req.Mail == "" && req.Phone == "" {
+		return nil, fmt.Errorf("invalid push mode: %q", d.Mode)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,105 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliverRequest(t *testing.T) {
+	item := &InvoiceResultItem{InvoiceCode: "033002000111", InvoiceNo: "12345678"}
+
+	req, err := deliverRequest("TAX", &Delivery{Mode: PushModeEmail, Email: "a@example.com"}, item)
+	require.NoError(t, err)
+	assert.Equal(t, "033002000111", req.InvoiceCode)
+	assert.Equal(t, "12345678", req.InvoiceNum)
+	assert.Equal(t, "a@example.com", req.Mail)
+	assert.Empty(t, req.Phone)
+
+	elec := &InvoiceResultItem{InvoiceCode: "", AllElectronicInvoiceNumbe: "24332000000012345678"}
+	req, err = deliverRequest("TAX", &Delivery{Mode: PushModeEmailAndSMS, Email: "a@example.com", Phone: "138"}, elec)
+	require.NoError(t, err)
+	assert.Equal(t, "24332000000012345678", req.InvoiceNum)
+	assert.Empty(t, req.InvoiceCode)
+	assert.Equal(t, "138", req.Phone)
+
+	_, err = deliverRequest("TAX", &Delivery{Mode: PushModeSMS}, item)
+	assert.EqualError(t, err, "phone required")
+
+	_, err = deliverRequest("TAX", &Delivery{Mode: PushModeEmail}, item)
+	assert.EqualError(t, err, "email required")
+
+	_, err = deliverRequest("TAX", &Delivery{Mode: PushModeNone}, item)
+	assert.Error(t, err)
+
+	_, err = deliverRequest("TAX", &Delivery{Mode: PushModeEmail, Email: "a@example.com"}, nil)
+	assert.Error(t, err)
+}
+
+func TestClient_RedeliverInvoices(t *testing.T) {
+	p := newFakePlatform(t)
+	p.handle("nuonuo.OpeMplatform.queryInvoiceResult", func(body []byte) (any, error) {
+		var req QueryInvoiceRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		items := []*InvoiceResultItem{}
+		for _, no := range req.SerialNos {
+			switch no {
+			case "S-fail":
+				return nil, errors.New("unavailable")
+			case "S-missing":
+				continue
+			}
+
+			items = append(items, &InvoiceResultItem{SerialNo: no, InvoiceCode: "033002000111", InvoiceNo: "12345678"})
+		}
+
+		return items, nil
+	})
+	p.handle("nuonuo.OpeMplatform.deliveryInvoice", func(body []byte) (any, error) {
+		return nil, nil
+	})
+
+	deliveries := []*Delivery{}
+	for i := 0; i < 100; i++ {
+		deliveries = append(deliveries, &Delivery{SerialNo: "S" + strconv.Itoa(i), Mode: PushModeSMS, Phone: "138"})
+	}
+
+	deliveries = append(deliveries,
+		&Delivery{SerialNo: "S-missing", Mode: PushModeSMS, Phone: "138"},
+		&Delivery{SerialNo: "S-fail", Mode: PushModeSMS, Phone: "138"},
+	)
+
+	results := p.client().RedeliverInvoices(context.Background(), "TAX", deliveries, 8)
+	require.Len(t, results, len(deliveries))
+
+	for _, r := range results[:100] {
+		assert.True(t, r.Accepted(), r.Delivery.SerialNo)
+	}
+
+	// S-missing 与 S-fail 在同一个查询失败的分片中，返回分片错误而不是发票不存在
+	var chunkErr *ChunkError
+	assert.ErrorAs(t, results[100].Err, &chunkErr)
+	assert.ErrorAs(t, results[101].Err, &chunkErr)
+
+	assert.Equal(t, 100, p.count("nuonuo.OpeMplatform.deliveryInvoice"))
+}
+
+func TestClient_RedeliverInvoices_NotFound(t *testing.T) {
+	p := newFakePlatform(t)
+	p.handle("nuonuo.OpeMplatform.queryInvoiceResult", func(body []byte) (any, error) {
+		return []*InvoiceResultItem{}, nil
+	})
+
+	results := p.client().RedeliverInvoices(context.Background(), "TAX", []*Delivery{
+		{SerialNo: "S-missing", Mode: PushModeSMS, Phone: "138"},
+	}, 0)
+	assert.EqualError(t, results[0].Err, "invoice S-missing not found")
+}
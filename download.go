@@ -0,0 +1,381 @@
+package nuonuo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const downloadMaxSize = 20 << 20 // 单个发票文件最大20MB
+
+// InvoiceFileFormat 发票文件格式
+type InvoiceFileFormat string
+
+const (
+	InvoiceFilePDF      InvoiceFileFormat = "pdf"       // 发票PDF（PdfURL）
+	InvoiceFileOFD      InvoiceFileFormat = "ofd"       // 发票OFD（OfdURL）
+	InvoiceFileXML      InvoiceFileFormat = "xml"       // 数电发票XML（XMLURL）
+	InvoiceFilePaperPDF InvoiceFileFormat = "paper_pdf" // 纸票PDF（PaperPdfURL）
+	InvoiceFilePicture  InvoiceFileFormat = "picture"   // 发票图片（PictureURL）
+	InvoiceFileImages   InvoiceFileFormat = "images"    // 发票图片列表（ImgURLs）
+)
+
+var (
+	ErrInvoiceFileNotFound = errors.New("invoice file not found")
+	ErrInvoiceFileTooLarge = errors.New("invoice file too large")
+	ErrInvalidFileName     = errors.New("invalid file name")
+)
+
+func newDownloadClient() *resty.Client {
+	c := resty.New()
+
+	return c.
+		SetLogger(discardLogger{}).
+		SetTransport(&limitedTransport{base: c.GetClient().Transport, limit: downloadMaxSize}).
+		SetTimeout(time.Minute).
+		SetRetryCount(3).
+		AddRetryCondition(func(r *resty.Response, err error) bool {
+			if errors.Is(err, ErrInvoiceFileTooLarge) {
+				return false
+			}
+
+			return err != nil || r.StatusCode() == http.StatusTooManyRequests || r.StatusCode() >= 500
+		})
+}
+
+// discardLogger 丢弃 resty 日志，下载失败通过返回的错误报告
+type discardLogger struct{}
+
+func (discardLogger) Errorf(format string, v ...any) {}
+func (discardLogger) Warnf(format string, v ...any)  {}
+func (discardLogger) Debugf(format string, v ...any) {}
+
+// limitedTransport 限制响应报文大小，resty 读取响应时超出限制即中止，避免将超大文件读入内存
+type limitedTransport struct {
+	base  http.RoundTripper
+	limit int64
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.ContentLength > t.limit {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes", ErrInvoiceFileTooLarge, resp.ContentLength)
+	}
+
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remain: t.limit}
+
+	return resp, nil
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	remain int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	// 多读一个字节以判断是否超出限制
+	if int64(len(p)) > b.remain+1 {
+		p = p[:b.remain+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+
+	b.remain -= int64(n)
+	if b.remain < 0 {
+		return n, ErrInvoiceFileTooLarge
+	}
+
+	return n, err
+}
+
+// ImageURLs 解析 ImgURLs 中以逗号分隔的图片地址
+func (item *InvoiceResultItem) ImageURLs() []string {
+	urls := []string{}
+	for _, u := range strings.Split(item.ImgURLs, ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			urls = append(urls, u)
+		}
+	}
+
+	return urls
+}
+
+// FileURLs 返回指定格式的文件地址，只有 InvoiceFileImages 可能返回多个
+func (item *InvoiceResultItem) FileURLs(format InvoiceFileFormat) []string {
+	var u string
+
+	switch format {
+	case InvoiceFilePDF:
+		u = item.PdfURL
+	case InvoiceFileOFD:
+		u = item.OfdURL
+	case InvoiceFileXML:
+		u = item.XMLURL
+	case InvoiceFilePaperPDF:
+		u = item.PaperPdfURL
+	case InvoiceFilePicture:
+		u = item.PictureURL
+	case InvoiceFileImages:
+		return item.ImageURLs()
+	}
+
+	if u == "" {
+		return nil
+	}
+
+	return []string{u}
+}
+
+// DownloadInvoiceFile 下载发票文件并写入 w。
+// 下载失败时自动重试，并校验文件大小与文件内容类型。
+// InvoiceFileImages 格式只下载第一张图片，全部下载请使用 DownloadInvoiceFiles。
+func (c *Client) DownloadInvoiceFile(
+	ctx context.Context, item *InvoiceResultItem, format InvoiceFileFormat, w io.Writer,
+) error {
+	urls := item.FileURLs(format)
+	if len(urls) == 0 {
+		return fmt.Errorf("%w: %s %s", ErrInvoiceFileNotFound, item.SerialNo, format)
+	}
+
+	data, err := c.download(ctx, urls[0], format)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+
+	return err
+}
+
+func (c *Client) download(ctx context.Context, fileURL string, format InvoiceFileFormat) ([]byte, error) {
+	resp, err := c.downloadClient.R().SetContext(ctx).Get(fileURL)
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", fileURL, err)
+	}
+
+	if resp.IsError() {
+		return nil, fmt.Errorf("download %s: http status: %s", fileURL, resp.Status())
+	}
+
+	data := resp.Body()
+	if len(data) == 0 {
+		return nil, fmt.Errorf("download %s: empty file", fileURL)
+	}
+
+	if cl := resp.Header().Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n != len(data) {
+			return nil, fmt.Errorf("download %s: size mismatch: want %d, got %d", fileURL, n, len(data))
+		}
+	}
+
+	if err := checkFileContent(format, resp.Header().Get("Content-Type"), data); err != nil {
+		return nil, fmt.Errorf("download %s: %w", fileURL, err)
+	}
+
+	return data, nil
+}
+
+// checkFileContent 校验文件内容与格式相符，避免将错误页面当作发票文件保存
+func checkFileContent(format InvoiceFileFormat, contentType string, data []byte) error {
+	if strings.HasPrefix(contentType, "text/html") {
+		return fmt.Errorf("unexpected content type: %s", contentType)
+	}
+
+	var ok bool
+
+	switch format {
+	case InvoiceFilePDF, InvoiceFilePaperPDF:
+		ok = bytes.HasPrefix(data, []byte("%PDF"))
+	case InvoiceFileOFD:
+		// OFD 文件为 zip 压缩包
+		ok = bytes.HasPrefix(data, []byte("PK"))
+	case InvoiceFileXML:
+		ok = bytes.HasPrefix(bytes.TrimSpace(data), []byte("<"))
+	case InvoiceFilePicture, InvoiceFileImages:
+		ok = strings.HasPrefix(http.DetectContentType(data), "image/")
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+
+	if !ok {
+		return fmt.Errorf("content is not %s", format)
+	}
+
+	return nil
+}
+
+// FileStorage 保存下载的发票文件
+type FileStorage interface {
+	Save(ctx context.Context, name string, data []byte) error
+}
+
+// LocalFileStorage 将发票文件保存到本地目录，文件名须为 Dir 下的相对路径
+type LocalFileStorage struct {
+	Dir string
+}
+
+func (s *LocalFileStorage) Save(ctx context.Context, name string, data []byte) error {
+	name = filepath.FromSlash(name)
+	if !filepath.IsLocal(name) {
+		return fmt.Errorf("%w: %s", ErrInvalidFileName, name)
+	}
+
+	p := filepath.Join(s.Dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+
+	// 先在同一目录写临时文件再重命名，避免留下不完整的文件，并发保存同名文件时临时文件互不影响
+	f, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Chmod(tmp, 0o644) // nolint: gosec
+	}
+
+	if err == nil {
+		err = os.Rename(tmp, p)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+
+	return err
+}
+
+// DownloadResult 单个发票文件的下载结果
+type DownloadResult struct {
+	SerialNo string
+	Format   InvoiceFileFormat
+	URL      string
+	Name     string // 保存的文件名
+	Size     int
+	Err      error
+}
+
+type downloadJob struct {
+	item   *InvoiceResultItem
+	format InvoiceFileFormat
+	url    string
+	name   string
+}
+
+// DownloadInvoiceFiles 并发下载多张发票的文件并保存到 storage。
+// 文件名为 {流水号}.{格式}，多张图片为 {流水号}_{序号}.{扩展名}。
+// 单个文件失败不影响其他文件，失败原因见各结果的 Err。
+func (c *Client) DownloadInvoiceFiles(
+	ctx context.Context,
+	items []*InvoiceResultItem,
+	formats []InvoiceFileFormat,
+	storage FileStorage,
+	concurrency int,
+) []*DownloadResult {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	jobs := []*downloadJob{}
+	for _, item := range items {
+		for _, format := range formats {
+			urls := item.FileURLs(format)
+			for i, u := range urls {
+				jobs = append(jobs, &downloadJob{
+					item:   item,
+					format: format,
+					url:    u,
+					name:   invoiceFileName(item.SerialNo, format, u, i, len(urls)),
+				})
+			}
+		}
+	}
+
+	results := make([]*DownloadResult, len(jobs))
+	sem := make(chan struct{}, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i, job := range jobs {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			result := &DownloadResult{
+				SerialNo: job.item.SerialNo,
+				Format:   job.format,
+				URL:      job.url,
+				Name:     job.name,
+			}
+			results[i] = result
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				result.Err = ctx.Err()
+				return
+			}
+			defer func() { <-sem }()
+
+			data, err := c.download(ctx, job.url, job.format)
+			if err != nil {
+				result.Err = err
+				return
+			}
+
+			result.Size = len(data)
+			result.Err = storage.Save(ctx, job.name, data)
+		}()
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func invoiceFileName(serialNo string, format InvoiceFileFormat, fileURL string, index, total int) string {
+	switch format {
+	case InvoiceFilePicture, InvoiceFileImages:
+		ext := ".jpg"
+		if u, err := url.Parse(fileURL); err == nil && path.Ext(u.Path) != "" {
+			ext = path.Ext(u.Path)
+		}
+
+		if total > 1 {
+			return serialNo + "_" + strconv.Itoa(index+1) + ext
+		}
+
+		return serialNo + "_" + string(format) + ext
+	case InvoiceFilePaperPDF:
+		return serialNo + "_paper.pdf"
+	default:
+		return serialNo + "." + string(format)
+	}
+}
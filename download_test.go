@@ -0,0 +1,143 @@
+package nuonuo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_DownloadInvoiceFiles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a.pdf":
+			_, _ = w.Write([]byte("%PDF-1.7 invoice"))
+		default:
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html>not found</html>"))
+		}
+	}))
+	defer srv.Close()
+
+	c := newClient()
+	item := &InvoiceResultItem{
+		SerialNo: "S1",
+		PdfURL:   srv.URL + "/a.pdf",
+		OfdURL:   srv.URL + "/a.ofd",
+		ImgURLs:  srv.URL + "/1.png, " + srv.URL + "/2.png",
+	}
+
+	assert.Equal(t, []string{srv.URL + "/1.png", srv.URL + "/2.png"}, item.ImageURLs())
+
+	var buf bytes.Buffer
+	require.NoError(t, c.DownloadInvoiceFile(context.Background(), item, InvoiceFilePDF, &buf))
+	assert.Equal(t, "%PDF-1.7 invoice", buf.String())
+
+	err := c.DownloadInvoiceFile(context.Background(), item, InvoiceFileXML, &buf)
+	assert.ErrorIs(t, err, ErrInvoiceFileNotFound)
+
+	dir := t.TempDir()
+	results := c.DownloadInvoiceFiles(
+		context.Background(),
+		[]*InvoiceResultItem{item},
+		[]InvoiceFileFormat{InvoiceFilePDF, InvoiceFileOFD, InvoiceFileImages},
+		&LocalFileStorage{Dir: dir},
+		2,
+	)
+	require.Len(t, results, 4)
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+	assert.Equal(t, "S1_2.png", results[3].Name)
+
+	data, err := os.ReadFile(filepath.Join(dir, "S1.pdf"))
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1.7 invoice", string(data))
+}
+
+func TestClient_DownloadInvoiceFile_TooLarge(t *testing.T) {
+	var requests atomic.Int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if r.URL.Path == "/chunked.pdf" {
+			// 分块传输，不携带 Content-Length
+			w.(http.Flusher).Flush()
+		}
+
+		_, _ = w.Write([]byte("%PDF-1.7 invoice"))
+	}))
+	defer srv.Close()
+
+	c := newClient()
+	c.downloadClient.SetTransport(&limitedTransport{base: http.DefaultTransport, limit: 10})
+
+	for _, name := range []string{"a.pdf", "chunked.pdf"} {
+		requests.Store(0)
+
+		item := &InvoiceResultItem{SerialNo: "S1", PdfURL: srv.URL + "/" + name}
+		err := c.DownloadInvoiceFile(context.Background(), item, InvoiceFilePDF, io.Discard)
+		assert.ErrorIs(t, err, ErrInvoiceFileTooLarge, name)
+
+		// 文件过大不重试
+		assert.Equal(t, int32(1), requests.Load(), name)
+	}
+
+	c.downloadClient.SetTransport(&limitedTransport{base: http.DefaultTransport, limit: 16})
+
+	var buf bytes.Buffer
+	item := &InvoiceResultItem{SerialNo: "S1", PdfURL: srv.URL + "/chunked.pdf"}
+	require.NoError(t, c.DownloadInvoiceFile(context.Background(), item, InvoiceFilePDF, &buf))
+	assert.Equal(t, "%PDF-1.7 invoice", buf.String())
+}
+
+func TestLocalFileStorage_Save(t *testing.T) {
+	dir := t.TempDir()
+	s := &LocalFileStorage{Dir: dir}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.Save(context.Background(), "a/S1.pdf", bytes.Repeat([]byte{'a' + byte(i)}, 1024)))
+		}()
+	}
+
+	wg.Wait()
+
+	// 并发保存同名文件时保留完整的某一次写入，不留下临时文件
+	data, err := os.ReadFile(filepath.Join(dir, "a", "S1.pdf"))
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat(data[:1], 1024), data)
+
+	entries, err := os.ReadDir(filepath.Join(dir, "a"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestLocalFileStorage_SaveOutsideDir(t *testing.T) {
+	dir := t.TempDir()
+	s := &LocalFileStorage{Dir: filepath.Join(dir, "files")}
+
+	// 文件名不能指向目录以外的位置
+	for _, name := range []string{"../S1.pdf", "a/../../S1.pdf", filepath.Join(dir, "S1.pdf"), "/S1.pdf", ""} {
+		assert.ErrorIs(t, s.Save(context.Background(), name, []byte("pdf")), ErrInvalidFileName, name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	require.NoError(t, s.Save(context.Background(), "a/../S1.pdf", []byte("pdf")))
+	assert.FileExists(t, filepath.Join(dir, "files", "S1.pdf"))
+}
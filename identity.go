@@ -0,0 +1,217 @@
+package nuonuo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidTaxNum        = errors.New("invalid tax number")
+	ErrInvalidInvoiceNumber = errors.New("invalid invoice number")
+)
+
+// creditCodeCharset 统一社会信用代码字符集（GB 32100），不含 I、O、Z、S、V
+const creditCodeCharset = "0123456789ABCDEFGHJKLMNPQRTUWXY"
+
+var creditCodeWeights = [17]int{1, 3, 9, 27, 19, 26, 16, 17, 20, 29, 25, 13, 8, 24, 10, 30, 28}
+
+// TaxNum 纳税人识别号，18位统一社会信用代码或15、17、20位旧税号
+type TaxNum string
+
+// ParseTaxNum 解析纳税人识别号，去除空白并转为大写，18位时校验统一社会信用代码校验位
+func ParseTaxNum(s string) (TaxNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+
+	switch len(s) {
+	case 18:
+		if !ValidCreditCode(s) {
+			return "", fmt.Errorf("%w: %s: bad unified social credit code", ErrInvalidTaxNum, s)
+		}
+	case 15, 17, 20:
+		if !isAlphanumeric(s) {
+			return "", fmt.Errorf("%w: %s", ErrInvalidTaxNum, s)
+		}
+	default:
+		return "", fmt.Errorf("%w: %s: length %d", ErrInvalidTaxNum, s, len(s))
+	}
+
+	return TaxNum(s), nil
+}
+
+// IsCreditCode 是否为统一社会信用代码
+func (n TaxNum) IsCreditCode() bool {
+	return len(n) == 18
+}
+
+func (n TaxNum) String() string {
+	return string(n)
+}
+
+// ValidCreditCode 校验统一社会信用代码的字符集与第18位校验码
+func ValidCreditCode(code string) bool {
+	if len(code) != 18 {
+		return false
+	}
+
+	sum := 0
+
+	for i := 0; i < 17; i++ {
+		v := strings.IndexByte(creditCodeCharset, code[i])
+		if v < 0 {
+			return false
+		}
+
+		sum += v * creditCodeWeights[i]
+	}
+
+	check := (31 - sum%31) % 31
+
+	return code[17] == creditCodeCharset[check]
+}
+
+// checkTaxNums 校验订单中的购销方税号。
+// 个人购方可能填写身份证号等证件号码，
+// 购方税号只在为18位且不是身份证号时校验统一社会信用代码。
+func checkTaxNums(order *InvoiceOrder) error {
+	if order.SalerTaxNum != "" {
+		if _, err := ParseTaxNum(order.SalerTaxNum); err != nil {
+			return err
+		}
+	}
+
+	buyer := strings.ToUpper(strings.TrimSpace(order.BuyerTaxNum))
+	if len(buyer) == 18 && !isIDCardNumber(buyer) && !ValidCreditCode(buyer) {
+		return fmt.Errorf("%w: buyer %s: bad unified social credit code", ErrInvalidTaxNum, buyer)
+	}
+
+	return nil
+}
+
+// isIDCardNumber 是否为18位居民身份证号码格式（17位数字加数字或X）
+func isIDCardNumber(s string) bool {
+	return len(s) == 18 && isDigits(s[:17]) && (s[17] == 'X' || isDigits(s[17:]))
+}
+
+func isAlphanumeric(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return s != ""
+}
+
+// InvoiceIdentity 发票标识，数电发票为20位数电票号码，其他发票为发票代码与8位发票号码
+type InvoiceIdentity struct {
+	Code       string // 发票代码，10位或12位
+	Number     string // 发票号码，8位
+	ElecNumber string // 数电票号码，20位
+}
+
+// ParseInvoiceIdentity 解析发票代码与号码，代码为空且号码为20位时视为数电发票
+func ParseInvoiceIdentity(code, number string) (InvoiceIdentity, error) {
+	code = strings.TrimSpace(code)
+	number = strings.TrimSpace(number)
+
+	id := InvoiceIdentity{Code: code, Number: number}
+	if code == "" && len(number) == 20 {
+		id = InvoiceIdentity{ElecNumber: number}
+	}
+
+	return id, id.Validate()
+}
+
+// InvoiceIdentityOf 返回发票查询结果的发票标识
+func InvoiceIdentityOf(item *InvoiceResultItem) (InvoiceIdentity, error) {
+	if item.AllElectronicInvoiceNumbe != "" {
+		id := InvoiceIdentity{ElecNumber: item.AllElectronicInvoiceNumbe}
+		return id, id.Validate()
+	}
+
+	if isAllElectronicLine(item.InvoiceKind) {
+		id := InvoiceIdentity{ElecNumber: item.InvoiceNo}
+		return id, id.Validate()
+	}
+
+	return ParseInvoiceIdentity(item.InvoiceCode, item.InvoiceNo)
+}
+
+// IsAllElectronic 是否为数电发票
+func (id InvoiceIdentity) IsAllElectronic() bool {
+	return id.ElecNumber != ""
+}
+
+func (id InvoiceIdentity) Validate() error {
+	if id.IsAllElectronic() {
+		if id.Code != "" || id.Number != "" {
+			return fmt.Errorf("%w: elec invoice number with invoice code or number", ErrInvalidInvoiceNumber)
+		}
+
+		if len(id.ElecNumber) != 20 || !isDigits(id.ElecNumber) {
+			return fmt.Errorf("%w: elec invoice number %q", ErrInvalidInvoiceNumber, id.ElecNumber)
+		}
+
+		return nil
+	}
+
+	if (len(id.Code) != 10 && len(id.Code) != 12) || !isDigits(id.Code) {
+		return fmt.Errorf("%w: invoice code %q", ErrInvalidInvoiceNumber, id.Code)
+	}
+
+	if len(id.Number) != 8 || !isDigits(id.Number) {
+		return fmt.Errorf("%w: invoice number %q", ErrInvalidInvoiceNumber, id.Number)
+	}
+
+	return nil
+}
+
+func (id InvoiceIdentity) String() string {
+	if id.IsAllElectronic() {
+		return id.ElecNumber
+	}
+
+	return id.Code + "-" + id.Number
+}
+
+// ApplyToFastRed 填写快捷冲红请求中的蓝票字段
+func (id InvoiceIdentity) ApplyToFastRed(req *FastInvoiceRedRequest) {
+	if id.IsAllElectronic() {
+		req.ElecInvoiceNumber = id.ElecNumber
+		req.InvoiceCode = ""
+		req.InvoiceNumber = ""
+
+		return
+	}
+
+	req.ElecInvoiceNumber = ""
+	req.InvoiceCode = id.Code
+	req.InvoiceNumber = id.Number
+}
+
+// ApplyToRedConfirm 填写红字确认单申请中的蓝票字段
+func (id InvoiceIdentity) ApplyToRedConfirm(req *SaveInvoiceRedConfirmRequest) {
+	if id.IsAllElectronic() {
+		req.BlueElecInvoiceNumber = id.ElecNumber
+		req.BlueInvoiceCode = ""
+		req.BlueInvoiceNumber = ""
+
+		return
+	}
+
+	req.BlueElecInvoiceNumber = ""
+	req.BlueInvoiceCode = id.Code
+	req.BlueInvoiceNumber = id.Number
+}
@@ -0,0 +1,68 @@
+package nuonuo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTaxNum(t *testing.T) {
+	n, err := ParseTaxNum(" 91350100m000100y43 ")
+	require.NoError(t, err)
+	assert.Equal(t, TaxNum("91350100M000100Y43"), n)
+	assert.True(t, n.IsCreditCode())
+
+	_, err = ParseTaxNum("91350100M000100Y44")
+	assert.ErrorIs(t, err, ErrInvalidTaxNum)
+
+	n, err = ParseTaxNum("339901999999199")
+	require.NoError(t, err)
+	assert.False(t, n.IsCreditCode())
+
+	_, err = ParseTaxNum("3399019999")
+	assert.ErrorIs(t, err, ErrInvalidTaxNum)
+
+	assert.ErrorIs(t, (&InvoiceOrder{BuyerTaxNum: "91350100M000100Y4O"}).Validate(), ErrInvalidTaxNum)
+
+	// 个人购方的身份证号与其他证件号码不按税号校验
+	assert.NoError(t, (&InvoiceOrder{BuyerTaxNum: "11010519491231002X"}).Validate())
+	assert.NoError(t, (&InvoiceOrder{BuyerTaxNum: "E12345678"}).Validate())
+	assert.ErrorIs(t, (&InvoiceOrder{SalerTaxNum: "3399019999"}).Validate(), ErrInvalidTaxNum)
+}
+
+func TestInvoiceIdentity(t *testing.T) {
+	id, err := InvoiceIdentityOf(&InvoiceResultItem{
+		InvoiceKind:               InvoiceLineAllElectronicNormal,
+		InvoiceNo:                 "24332000000012345678",
+		AllElectronicInvoiceNumbe: "24332000000012345678",
+	})
+	require.NoError(t, err)
+	assert.True(t, id.IsAllElectronic())
+
+	red := &FastInvoiceRedRequest{InvoiceCode: "stale"}
+	id.ApplyToFastRed(red)
+	assert.Equal(t, "24332000000012345678", red.ElecInvoiceNumber)
+	assert.Empty(t, red.InvoiceCode)
+
+	id, err = InvoiceIdentityOf(&InvoiceResultItem{
+		InvoiceKind: "增值税电子普通发票",
+		InvoiceCode: "033002000111",
+		InvoiceNo:   "12345678",
+	})
+	require.NoError(t, err)
+	assert.False(t, id.IsAllElectronic())
+
+	confirm := &SaveInvoiceRedConfirmRequest{}
+	id.ApplyToRedConfirm(confirm)
+	assert.Equal(t, "033002000111", confirm.BlueInvoiceCode)
+	assert.Equal(t, "12345678", confirm.BlueInvoiceNumber)
+	assert.Empty(t, confirm.BlueElecInvoiceNumber)
+
+	id, err = ParseInvoiceIdentity("", "24332000000012345678")
+	require.NoError(t, err)
+	assert.True(t, id.IsAllElectronic())
+
+	_, err = ParseInvoiceIdentity("0330020001", "1234567")
+	assert.ErrorIs(t, err, ErrInvalidInvoiceNumber)
+}
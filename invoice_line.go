@@ -0,0 +1,51 @@
+package nuonuo
+
+// 发票种类（invoiceLine、invoiceKind）
+const (
+	InvoiceLineElectronicNormal          = "p"  // 电子增值税普通发票
+	InvoiceLinePaperNormal               = "c"  // 增值税普通发票（纸票）
+	InvoiceLinePaperSpecial              = "s"  // 增值税专用发票（纸票）
+	InvoiceLineElectronicPurchase        = "e"  // 收购发票（电子）
+	InvoiceLinePaperPurchase             = "f"  // 收购发票（纸质）
+	InvoiceLineRollNormal                = "r"  // 增值税普通发票（卷式）
+	InvoiceLineElectronicSpecial         = "b"  // 增值税电子专用发票
+	InvoiceLineVehicle                   = "j"  // 机动车销售统一发票
+	InvoiceLineUsedVehicle               = "u"  // 二手车销售统一发票
+	InvoiceLineAllElectronicSpecial      = "bs" // 电子发票（增值税专用发票），即数电专票
+	InvoiceLineAllElectronicNormal       = "pc" // 电子发票（普通发票），即数电普票
+	InvoiceLineAllElectronicPaperSpecial = "es" // 数电纸质发票（增值税专用发票）
+	InvoiceLineAllElectronicPaperNormal  = "ec" // 数电纸质发票（普通发票）
+)
+
+// 是否数电发票
+func isAllElectronicLine(line string) bool {
+	switch line {
+	case InvoiceLineAllElectronicSpecial, InvoiceLineAllElectronicNormal,
+		InvoiceLineAllElectronicPaperSpecial, InvoiceLineAllElectronicPaperNormal:
+		return true
+	default:
+		return false
+	}
+}
+
+// 是否增值税专用发票
+func isSpecialLine(line string) bool {
+	switch line {
+	case InvoiceLinePaperSpecial, InvoiceLineElectronicSpecial,
+		InvoiceLineAllElectronicSpecial, InvoiceLineAllElectronicPaperSpecial:
+		return true
+	default:
+		return false
+	}
+}
+
+// 是否税控纸质发票
+func isLegacyPaperLine(line string) bool {
+	switch line {
+	case InvoiceLinePaperNormal, InvoiceLinePaperSpecial, InvoiceLinePaperPurchase, InvoiceLineRollNormal,
+		InvoiceLineVehicle, InvoiceLineUsedVehicle:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,281 @@
+package nuonuo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeError 响应解析问题。
+// 字段类型不符时尽量转换（如字符串 "1" 与数字 1 互转），仍无法解析的字段保留零值，
+// 不影响其他字段，并通过 OnDecodeWarning 注册的函数报告。
+type DecodeError struct {
+	Method string
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %s response: %v", e.Method, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// OnDecodeWarning 注册响应解析问题的处理函数，可用于记录日志或监控，未注册时忽略
+func (c *Client) OnDecodeWarning(fn func(ctx context.Context, warning *DecodeError)) {
+	c.decodeWarning = fn
+}
+
+func (c *Client) warnDecode(ctx context.Context, method string, err error) {
+	if c.decodeWarning != nil {
+		c.decodeWarning(ctx, &DecodeError{Method: method, Err: err})
+	}
+}
+
+// decodeLenient 解析 JSON，字段类型不符时先转换为目标类型后重新解析。
+// 返回的 warning 为非致命问题，err 为无法解析的错误。
+func decodeLenient(data []byte, v any) (warning, err error) {
+	err = json.Unmarshal(data, v)
+
+	var typeErr *json.UnmarshalTypeError
+	if err == nil || !errors.As(err, &typeErr) {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var raw any
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	normalized, err := json.Marshal(normalizeJSON(raw, reflect.TypeOf(v)))
+	if err != nil {
+		return nil, err
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+
+	// 已解析的字段不受剩余类型错误影响，剩余错误作为警告报告
+	if err := json.Unmarshal(normalized, v); err != nil {
+		if !errors.As(err, &typeErr) {
+			return nil, err
+		}
+
+		return err, nil
+	}
+
+	return nil, nil
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// normalizeJSON 按目标类型转换 JSON 值中的字符串、数字与布尔值
+func normalizeJSON(v any, t reflect.Type) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if v == nil || reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return v
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+
+		fields := map[string]reflect.Type{}
+		structFields(t, fields)
+
+		for key, value := range obj {
+			if ft := lookupField(fields, key); ft != nil {
+				obj[key] = normalizeJSON(value, ft)
+			}
+		}
+
+		return obj
+	case reflect.Map:
+		if obj, ok := v.(map[string]any); ok {
+			for key, value := range obj {
+				obj[key] = normalizeJSON(value, t.Elem())
+			}
+		}
+
+		return v
+	case reflect.Slice, reflect.Array:
+		if list, ok := v.([]any); ok {
+			for i, value := range list {
+				list[i] = normalizeJSON(value, t.Elem())
+			}
+		}
+
+		return v
+	case reflect.String:
+		switch s := v.(type) {
+		case json.Number:
+			return string(s)
+		case bool:
+			return strconv.FormatBool(s)
+		}
+
+		return v
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return normalizeInt(v)
+	case reflect.Float32, reflect.Float64:
+		if s, ok := v.(string); ok {
+			s = strings.TrimSpace(s)
+			if s == "" {
+				return json.Number("0")
+			}
+
+			if _, err := strconv.ParseFloat(s, 64); err == nil {
+				return json.Number(s)
+			}
+		}
+
+		return v
+	case reflect.Bool:
+		return normalizeBool(v)
+	default:
+		return v
+	}
+}
+
+func normalizeInt(v any) any {
+	switch s := v.(type) {
+	case bool:
+		if s {
+			return json.Number("1")
+		}
+
+		return json.Number("0")
+	case json.Number:
+		if n, ok := integralNumber(string(s)); ok {
+			return n
+		}
+	case string:
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return json.Number("0")
+		}
+
+		if n, ok := integralNumber(s); ok {
+			return n
+		}
+	}
+
+	return v
+}
+
+// integralNumber 解析整数，兼容 1.0 等整数值的浮点数
+func integralNumber(s string) (json.Number, bool) {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return json.Number(s), true
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f != math.Trunc(f) || math.Abs(f) >= 1<<53 {
+		return "", false
+	}
+
+	return json.Number(strconv.FormatInt(int64(f), 10)), true
+}
+
+func normalizeBool(v any) any {
+	var s string
+
+	switch b := v.(type) {
+	case json.Number:
+		s = string(b)
+	case string:
+		s = strings.ToLower(strings.TrimSpace(b))
+	default:
+		return v
+	}
+
+	switch s {
+	case "1", "true", "y", "yes":
+		return true
+	case "0", "false", "n", "no", "":
+		return false
+	}
+
+	return v
+}
+
+// structFields 收集结构体的 JSON 字段，嵌入结构体的字段优先级低于外层字段
+func structFields(t reflect.Type, fields map[string]reflect.Type) {
+	embedded := []reflect.Type{}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fields[name] = f.Type
+	}
+
+	for _, et := range embedded {
+		inner := map[string]reflect.Type{}
+		structFields(et, inner)
+
+		for name, ft := range inner {
+			if _, ok := fields[name]; !ok {
+				fields[name] = ft
+			}
+		}
+	}
+}
+
+// lookupField 与 encoding/json 一致，字段名不区分大小写
+func lookupField(fields map[string]reflect.Type, key string) reflect.Type {
+	if ft, ok := fields[key]; ok {
+		return ft
+	}
+
+	for name, ft := range fields {
+		if strings.EqualFold(name, key) {
+			return ft
+		}
+	}
+
+	return nil
+}
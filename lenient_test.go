@@ -0,0 +1,55 @@
+package nuonuo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeLenient(t *testing.T) {
+	var items []*InvoiceResultItem
+
+	warning, err := decodeLenient([]byte(`[{
+		"serialNo": "24030514300001",
+		"status": 2,
+		"invoiceTime": "1709620200000",
+		"productOilFlag": "1",
+		"specificFactor": "",
+		"orderAmount": 106.5
+	}]`), &items)
+	require.NoError(t, err)
+	require.NoError(t, warning)
+	require.Len(t, items, 1)
+
+	item := items[0]
+	assert.Equal(t, "24030514300001", item.SerialNo)
+	assert.Equal(t, InvoiceStatusCompleted, item.Status)
+	assert.Equal(t, int64(1709620200000), item.InvoiceTime)
+	assert.Equal(t, 1, item.ProductOilFlag)
+	assert.Equal(t, 0, item.SpecificFactor)
+	assert.Equal(t, "106.5", item.OrderAmount)
+}
+
+func TestDecodeLenient_Warning(t *testing.T) {
+	var resp QueryInvoiceRedConfirmResponse
+
+	warning, err := decodeLenient([]byte(`{
+		"total": "1",
+		"list": [{"billNo": "B001", "openStatus": "unknown", "applySource": "0"}]
+	}`), &resp)
+	require.NoError(t, err)
+	require.Error(t, warning)
+
+	var typeErr *json.UnmarshalTypeError
+	assert.ErrorAs(t, warning, &typeErr)
+
+	assert.Equal(t, 1, resp.Total)
+	require.Len(t, resp.List, 1)
+	assert.Equal(t, "B001", resp.List[0].BillNo)
+	assert.Equal(t, 0, resp.List[0].OpenStatus)
+
+	_, err = decodeLenient([]byte(`{"total":`), &resp)
+	assert.Error(t, err)
+}
@@ -0,0 +1,259 @@
+package nuonuo
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	defaultListName = "详见销货清单"
+	remarkMaxLen    = 200 // 备注最大长度（字符）
+)
+
+// InvoiceLimits 单张发票的开具限制，未设置的字段使用 DefaultInvoiceLimits 中的值，
+// 行数限制设为负数表示不限制
+type InvoiceLimits struct {
+	MaxLines     int    // 不使用清单时的最大明细行数，超出后自动开具清单，数电发票不适用
+	MaxListLines int    // 使用清单时的最大明细行数
+	MaxAmount    string // 单张发票不含税金额限额，空表示不限制
+}
+
+// withDefaults 按字段使用默认值补全未设置的限制
+func (l InvoiceLimits) withDefaults() InvoiceLimits {
+	if l.MaxLines == 0 {
+		l.MaxLines = DefaultInvoiceLimits.MaxLines
+	}
+
+	if l.MaxListLines == 0 {
+		l.MaxListLines = DefaultInvoiceLimits.MaxListLines
+	}
+
+	if l.MaxAmount == "" {
+		l.MaxAmount = DefaultInvoiceLimits.MaxAmount
+	}
+
+	return l
+}
+
+// DefaultInvoiceLimits 税控发票的默认开具限制
+var DefaultInvoiceLimits = InvoiceLimits{
+	MaxLines:     8,
+	MaxListLines: 2000,
+}
+
+var (
+	ErrMismatchedParty = errors.New("orders have different buyer or seller")
+	ErrExceedsLimit    = errors.New("invoice exceeds limits")
+)
+
+// OrderMerger 将同一购销双方的多张订单合并为一张发票
+type OrderMerger struct {
+	Limits InvoiceLimits
+
+	// 是否在备注中写入来源订单号
+	RemarkSourceOrders bool
+}
+
+type MergedOrder struct {
+	Order *InvoiceOrder
+
+	// LineSources[i] 为 Order.InvoiceDetail[i] 的来源订单号
+	LineSources [][]string
+}
+
+type mergeKey struct {
+	goodsCode, taxRate, unit, price string
+
+	withTaxFlag, favouredPolicyFlag, favouredPolicyName, zeroRateFlag string
+}
+
+func newMergeKey(item *GoodsItem) mergeKey {
+	return mergeKey{
+		goodsCode:          item.GoodsCode,
+		taxRate:            item.TaxRate,
+		unit:               item.Unit,
+		price:              item.Price,
+		withTaxFlag:        item.WithTaxFlag,
+		favouredPolicyFlag: item.FavouredPolicyFlag,
+		favouredPolicyName: item.FavouredPolicyName,
+		zeroRateFlag:       item.ZeroRateFlag,
+	}
+}
+
+type mergeLine struct {
+	item    *GoodsItem
+	sources []string
+
+	num, excluded, included, tax *big.Rat
+}
+
+// Merge 合并订单。商品编码、税率、单位与单价相同的正常行会汇总数量与金额，
+// 折扣行、被折扣行与差额征税行原样保留。
+func (m *OrderMerger) Merge(orderNo string, orders ...*InvoiceOrder) (*MergedOrder, error) {
+	if len(orders) == 0 {
+		return nil, errors.New("no orders to merge")
+	}
+
+	first := orders[0]
+	for _, o := range orders[1:] {
+		if o.BuyerName != first.BuyerName || o.BuyerTaxNum != first.BuyerTaxNum ||
+			o.SalerTaxNum != first.SalerTaxNum || o.InvoiceLine != first.InvoiceLine {
+			return nil, fmt.Errorf("%w: order %s", ErrMismatchedParty, o.OrderNo)
+		}
+	}
+
+	lines := []*mergeLine{}
+	index := map[mergeKey]*mergeLine{}
+
+	for _, o := range orders {
+		for _, item := range o.InvoiceDetail {
+			excluded, included, tax, err := lineAmounts(item)
+			if err != nil {
+				return nil, fmt.Errorf("order %s: %w", o.OrderNo, err)
+			}
+
+			num, err := parseDecimal(item.Num)
+			if err != nil {
+				return nil, fmt.Errorf("order %s: num: %w", o.OrderNo, err)
+			}
+
+			mergeable := (item.InvoiceLineProperty == "" || item.InvoiceLineProperty == "0") &&
+				item.Deduction == ""
+
+			if mergeable {
+				key := newMergeKey(item)
+				if l, ok := index[key]; ok {
+					l.num.Add(l.num, num)
+					l.excluded.Add(l.excluded, excluded)
+					l.included.Add(l.included, included)
+					l.tax.Add(l.tax, tax)
+					l.sources = appendUnique(l.sources, o.OrderNo)
+
+					continue
+				}
+			}
+
+			itemCopy := *item
+			l := &mergeLine{
+				item:     &itemCopy,
+				sources:  []string{o.OrderNo},
+				num:      num,
+				excluded: excluded,
+				included: included,
+				tax:      tax,
+			}
+			lines = append(lines, l)
+
+			if mergeable {
+				index[newMergeKey(item)] = l
+			}
+		}
+	}
+
+	merged := *first
+	merged.OrderNo = orderNo
+	merged.ListFlag = ""
+	merged.ListName = ""
+	merged.InvoiceDetail = make([]*GoodsItem, 0, len(lines))
+
+	result := &MergedOrder{
+		Order:       &merged,
+		LineSources: make([][]string, 0, len(lines)),
+	}
+
+	total := new(big.Rat)
+	for _, l := range lines {
+		if l.item.Num != "" {
+			l.item.Num = formatQuantity(l.num)
+		}
+
+		l.item.TaxExcludedAmount = formatAmount(l.excluded)
+		l.item.TaxIncludedAmount = formatAmount(l.included)
+		l.item.Tax = formatAmount(l.tax)
+
+		total.Add(total, l.excluded)
+
+		merged.InvoiceDetail = append(merged.InvoiceDetail, l.item)
+		result.LineSources = append(result.LineSources, l.sources)
+	}
+
+	if err := m.applyLimits(&merged, total); err != nil {
+		return nil, err
+	}
+
+	if m.RemarkSourceOrders {
+		sources := make([]string, 0, len(orders))
+		for _, o := range orders {
+			sources = appendUnique(sources, o.OrderNo)
+		}
+
+		merged.Remark = mergeRemark(first.Remark, sources)
+	}
+
+	return result, nil
+}
+
+func (m *OrderMerger) applyLimits(order *InvoiceOrder, total *big.Rat) error {
+	limits := m.Limits.withDefaults()
+
+	n := len(order.InvoiceDetail)
+	if limits.MaxListLines > 0 && n > limits.MaxListLines {
+		return fmt.Errorf("%w: %d lines, max %d", ErrExceedsLimit, n, limits.MaxListLines)
+	}
+
+	// 数电发票不使用清单
+	if limits.MaxLines > 0 && n > limits.MaxLines && !isAllElectronicLine(order.InvoiceLine) {
+		order.ListFlag = "1"
+		order.ListName = defaultListName
+	}
+
+	if limits.MaxAmount != "" {
+		maxAmount, err := parseDecimal(limits.MaxAmount)
+		if err != nil {
+			return fmt.Errorf("max amount: %w", err)
+		}
+
+		if total.Cmp(maxAmount) > 0 {
+			return fmt.Errorf("%w: amount %s, max %s", ErrExceedsLimit, formatAmount(total), limits.MaxAmount)
+		}
+	}
+
+	return nil
+}
+
+// mergeRemark 在原备注后追加来源订单号，超出长度时省略部分订单号。
+func mergeRemark(remark string, sources []string) string {
+	prefix := remark
+	if prefix != "" {
+		prefix += " "
+	}
+
+	prefix += "合并订单："
+
+	for n := len(sources); n > 0; n-- {
+		s := prefix + strings.Join(sources[:n], "、")
+		if n < len(sources) {
+			s += "等" + strconv.Itoa(len(sources)) + "单"
+		}
+
+		if utf8.RuneCountInString(s) <= remarkMaxLen {
+			return s
+		}
+	}
+
+	return remark
+}
+
+func appendUnique(ss []string, s string) []string {
+	for _, v := range ss {
+		if v == s {
+			return ss
+		}
+	}
+
+	return append(ss, s)
+}
@@ -0,0 +1,96 @@
+package nuonuo
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderMerger_Merge(t *testing.T) {
+	newOrder := func(orderNo string, items ...*GoodsItem) *InvoiceOrder {
+		return &InvoiceOrder{
+			BuyerName:     "购方",
+			SalerTaxNum:   "339901999999199",
+			OrderNo:       orderNo,
+			Remark:        "月结",
+			InvoiceDetail: items,
+		}
+	}
+
+	m := &OrderMerger{RemarkSourceOrders: true}
+
+	merged, err := m.Merge(
+		"M1",
+		newOrder("A", &GoodsItem{
+			GoodsCode: "1010101020000000000", TaxRate: "0.13", Unit: "个", Price: "10", Num: "2", WithTaxFlag: "1",
+		}),
+		newOrder("B",
+			&GoodsItem{
+				GoodsCode: "1010101020000000000", TaxRate: "0.13", Unit: "个", Price: "10", Num: "3", WithTaxFlag: "1",
+			},
+			&GoodsItem{
+				GoodsCode: "3040201010000000000", TaxRate: "0.06", TaxIncludedAmount: "106", WithTaxFlag: "1",
+			},
+		),
+	)
+	require.NoError(t, err)
+
+	order := merged.Order
+	require.Len(t, order.InvoiceDetail, 2)
+	assert.Equal(t, "M1", order.OrderNo)
+	assert.Equal(t, "5", order.InvoiceDetail[0].Num)
+	assert.Equal(t, "50.00", order.InvoiceDetail[0].TaxIncludedAmount)
+	assert.Equal(t, "5.75", order.InvoiceDetail[0].Tax)
+	assert.Equal(t, "6.00", order.InvoiceDetail[1].Tax)
+	assert.Equal(t, [][]string{{"A", "B"}, {"B"}}, merged.LineSources)
+	assert.Equal(t, "月结 合并订单：A、B", order.Remark)
+	assert.Empty(t, order.ListFlag)
+
+	_, err = m.Merge("M2", newOrder("A"), &InvoiceOrder{BuyerName: "其他"})
+	assert.ErrorIs(t, err, ErrMismatchedParty)
+}
+
+func TestOrderMerger_MergeList(t *testing.T) {
+	items := make([]*GoodsItem, 0, 10)
+	for i := 0; i < 10; i++ {
+		items = append(items, &GoodsItem{
+			GoodsCode: "1010101020000000000", TaxRate: "0.13", Price: strconv.Itoa(i + 1), Num: "1",
+		})
+	}
+
+	m := &OrderMerger{}
+	merged, err := m.Merge("M1", &InvoiceOrder{OrderNo: "A", InvoiceDetail: items})
+	require.NoError(t, err)
+	assert.Equal(t, "1", merged.Order.ListFlag)
+	assert.Equal(t, defaultListName, merged.Order.ListName)
+
+	m.Limits = InvoiceLimits{MaxListLines: 5}
+	_, err = m.Merge("M1", &InvoiceOrder{OrderNo: "A", InvoiceDetail: items})
+	assert.ErrorIs(t, err, ErrExceedsLimit)
+
+	// 只设置金额限额时行数限制仍使用默认值
+	m.Limits = InvoiceLimits{MaxAmount: "45"}
+	merged, err = m.Merge("M1", &InvoiceOrder{OrderNo: "A", InvoiceDetail: items[:9]})
+	require.NoError(t, err)
+	assert.Equal(t, "1", merged.Order.ListFlag)
+
+	_, err = m.Merge("M1", &InvoiceOrder{OrderNo: "A", InvoiceDetail: items})
+	assert.ErrorIs(t, err, ErrExceedsLimit)
+
+	// 数电发票不开具清单
+	m.Limits = InvoiceLimits{}
+	merged, err = m.Merge("M1", &InvoiceOrder{
+		OrderNo: "A", InvoiceLine: InvoiceLineAllElectronicNormal, InvoiceDetail: items,
+	})
+	require.NoError(t, err)
+	assert.Empty(t, merged.Order.ListFlag)
+	assert.Empty(t, merged.Order.ListName)
+
+	// 负数表示不限制行数
+	m.Limits = InvoiceLimits{MaxLines: -1, MaxListLines: -1}
+	merged, err = m.Merge("M1", &InvoiceOrder{OrderNo: "A", InvoiceDetail: items})
+	require.NoError(t, err)
+	assert.Empty(t, merged.Order.ListFlag)
+}
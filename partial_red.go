@@ -0,0 +1,229 @@
+package nuonuo
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+var ErrRedExceedsBlue = errors.New("red amount exceeds blue amount")
+
+// PartialRedLine 部分冲红的蓝票明细行
+type PartialRedLine struct {
+	Index  int    // 蓝票明细行下标，从0开始
+	Num    string // 冲红数量（正数），为空时按金额冲红
+	Amount string // 冲红含税金额（正数），与数量均为空时冲红整行
+}
+
+// PartialRedRequest 数电发票部分冲红
+type PartialRedRequest struct {
+	Blue       *InvoiceResultItem
+	BlueDetail []*GoodsItem // 蓝票开具时的明细行
+	Lines      []*PartialRedLine
+	Reason     string // 冲红原因
+
+	// 该蓝票此前已冲红的含税金额合计（正数）
+	RedAmountIssued string
+	// 各蓝票明细行此前已冲红的含税金额（正数），键为明细行下标
+	LineRedIssued map[int]string
+}
+
+// NewPartialRedReversal 计算部分冲红明细并返回对应的冲红流程。
+// 冲红明细写入红字确认单，红票按确认单金额开具。
+// 冲红明细保存在 State.Detail 中，恢复流程时需使用保存的 State。
+// 同一蓝票可多次部分冲红，每次冲红的红票订单号由红字确认单申请号生成，互不重复。
+func (c *Client) NewPartialRedReversal(req *PartialRedRequest) (*RedReversal, error) {
+	r := NewRedReversal(c, req.Blue, req.Reason)
+	if !r.isAllElectronic() {
+		return nil, errors.New("partial red reversal requires an all-electronic invoice")
+	}
+
+	items, err := PartialRedItems(req.BlueDetail, req.Lines, req.RedAmountIssued, req.LineRedIssued)
+	if err != nil {
+		return nil, err
+	}
+
+	r.State.BillID = newBillID()
+	r.State.OrderNo = partialRedOrderNo(req.Blue, r.State.BillID)
+
+	r.State.Detail = make([]*RedConfirmDetail, 0, len(items))
+	for i, item := range items {
+		r.State.Detail = append(r.State.Detail, &RedConfirmDetail{
+			BlueDetailIndex:   strconv.Itoa(req.Lines[i].Index + 1),
+			GoodsName:         item.GoodsName,
+			GoodsCode:         item.GoodsCode,
+			SpecType:          item.SpecType,
+			Unit:              item.Unit,
+			Num:               item.Num,
+			Price:             item.Price,
+			TaxRate:           item.TaxRate,
+			TaxExcludedAmount: item.TaxExcludedAmount,
+			Tax:               item.Tax,
+			TaxIncludedAmount: item.TaxIncludedAmount,
+		})
+	}
+
+	return r, nil
+}
+
+// PartialRedItems 根据蓝票明细计算负数明细行，lines 中每行对应返回结果中的一行。
+// 同一蓝票明细行的冲红金额加上 lineRedIssued 中该行此前已冲红的金额不能超过该行金额，
+// 累计冲红金额加上 redAmountIssued 不能超过蓝票金额。
+func PartialRedItems(
+	blueDetail []*GoodsItem, lines []*PartialRedLine, redAmountIssued string, lineRedIssued map[int]string,
+) ([]*GoodsItem, error) {
+	if len(lines) == 0 {
+		return nil, errors.New("no lines to reverse")
+	}
+
+	blueTotal := new(big.Rat)
+	for _, item := range blueDetail {
+		_, included, _, err := lineAmounts(item)
+		if err != nil {
+			return nil, err
+		}
+
+		blueTotal.Add(blueTotal, included)
+	}
+
+	total, err := parseDecimal(redAmountIssued)
+	if err != nil {
+		return nil, fmt.Errorf("red amount issued: %w", err)
+	}
+
+	total.Abs(total)
+
+	items := make([]*GoodsItem, 0, len(lines))
+	byIndex := map[int]*big.Rat{} // 各蓝票明细行的冲红含税金额合计
+
+	for _, line := range lines {
+		if line.Index < 0 || line.Index >= len(blueDetail) {
+			return nil, fmt.Errorf("line index %d out of range", line.Index)
+		}
+
+		blue := blueDetail[line.Index]
+
+		_, blueIncluded, _, err := lineAmounts(blue)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.Index, err)
+		}
+
+		item, included, err := partialRedItem(blue, line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line.Index, err)
+		}
+
+		sum, ok := byIndex[line.Index]
+		if !ok {
+			sum, err = parseDecimal(lineRedIssued[line.Index])
+			if err != nil {
+				return nil, fmt.Errorf("line %d red amount issued: %w", line.Index, err)
+			}
+
+			sum.Abs(sum)
+			byIndex[line.Index] = sum
+		}
+
+		sum.Add(sum, included)
+		if sum.Cmp(blueIncluded) > 0 {
+			return nil, fmt.Errorf("%w: line %d red %s, blue %s",
+				ErrRedExceedsBlue, line.Index, formatAmount(sum), formatAmount(blueIncluded))
+		}
+
+		total.Add(total, included)
+		items = append(items, item)
+	}
+
+	if total.Cmp(blueTotal) > 0 {
+		return nil, fmt.Errorf("%w: red %s, blue %s", ErrRedExceedsBlue, formatAmount(total), formatAmount(blueTotal))
+	}
+
+	return items, nil
+}
+
+// partialRedItem 计算单行负数明细，同时返回冲红含税金额（正数）
+func partialRedItem(blue *GoodsItem, line *PartialRedLine) (*GoodsItem, *big.Rat, error) {
+	excluded, included, tax, err := lineAmounts(blue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rate, err := parseDecimal(blue.TaxRate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	red := *blue
+	red.Deduction = ""
+
+	switch {
+	case line.Num != "":
+		num, err := parseDecimal(line.Num)
+		if err != nil {
+			return nil, nil, fmt.Errorf("num: %w", err)
+		}
+
+		blueNum, err := parseDecimal(blue.Num)
+		if err != nil || blueNum.Sign() == 0 {
+			return nil, nil, errors.New("blue line has no quantity")
+		}
+
+		if num.Sign() <= 0 || num.Cmp(blueNum) > 0 {
+			return nil, nil, fmt.Errorf("%w: num %s, blue %s", ErrRedExceedsBlue, line.Num, blue.Num)
+		}
+
+		if num.Cmp(blueNum) < 0 {
+			ratio := new(big.Rat).Quo(num, blueNum)
+			if blue.WithTaxFlag == "0" {
+				excluded = roundAmount(new(big.Rat).Mul(excluded, ratio))
+				tax = calcTax(nil, excluded, new(big.Rat), rate)
+				included = new(big.Rat).Add(excluded, tax)
+			} else {
+				included = roundAmount(new(big.Rat).Mul(included, ratio))
+				tax = calcTax(included, nil, new(big.Rat), rate)
+				excluded = new(big.Rat).Sub(included, tax)
+			}
+		}
+
+		red.Num = formatQuantity(new(big.Rat).Neg(num))
+	case line.Amount != "":
+		amount, err := parseDecimal(line.Amount)
+		if err != nil {
+			return nil, nil, fmt.Errorf("amount: %w", err)
+		}
+
+		if amount.Sign() <= 0 || amount.Cmp(included) > 0 {
+			return nil, nil, fmt.Errorf(
+				"%w: amount %s, blue %s", ErrRedExceedsBlue, line.Amount, formatAmount(included),
+			)
+		}
+
+		if amount.Cmp(included) < 0 {
+			included = roundAmount(amount)
+			tax = calcTax(included, nil, new(big.Rat), rate)
+			excluded = new(big.Rat).Sub(included, tax)
+
+			// 按金额冲红时不体现数量与单价
+			red.Num = ""
+			red.Price = ""
+		} else if red.Num != "" {
+			red.Num = "-" + red.Num
+		}
+	default:
+		if red.Num != "" {
+			red.Num = "-" + red.Num
+		}
+	}
+
+	red.TaxExcludedAmount = formatAmount(new(big.Rat).Neg(excluded))
+	red.TaxIncludedAmount = formatAmount(new(big.Rat).Neg(included))
+	red.Tax = formatAmount(new(big.Rat).Neg(tax))
+
+	return &red, included, nil
+}
+
+// partialRedOrderNo 由蓝票订单号与红字确认单申请号生成部分冲红的红票订单号
+func partialRedOrderNo(blue *InvoiceResultItem, billID string) string {
+	return redOrderNo(blue) + billID[:8]
+}
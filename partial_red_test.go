@@ -0,0 +1,184 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialRedItems(t *testing.T) {
+	blue := []*GoodsItem{
+		{GoodsName: "A", TaxRate: "0.13", Price: "113", Num: "3", WithTaxFlag: "1"},
+		{GoodsName: "B", TaxRate: "0.06", TaxIncludedAmount: "106", WithTaxFlag: "1"},
+	}
+
+	items, err := PartialRedItems(blue, []*PartialRedLine{
+		{Index: 0, Num: "1"},
+		{Index: 1, Amount: "53"},
+	}, "", nil)
+	require.NoError(t, err)
+	require.Len(t, items, 2)
+
+	assert.Equal(t, "-1", items[0].Num)
+	assert.Equal(t, "-113.00", items[0].TaxIncludedAmount)
+	assert.Equal(t, "-100.00", items[0].TaxExcludedAmount)
+	assert.Equal(t, "-13.00", items[0].Tax)
+
+	assert.Empty(t, items[1].Num)
+	assert.Equal(t, "-53.00", items[1].TaxIncludedAmount)
+	assert.Equal(t, "-3.00", items[1].Tax)
+
+	_, err = PartialRedItems(blue, []*PartialRedLine{{Index: 0, Num: "4"}}, "", nil)
+	assert.ErrorIs(t, err, ErrRedExceedsBlue)
+
+	_, err = PartialRedItems(blue, []*PartialRedLine{{Index: 1}}, "400", nil)
+	assert.ErrorIs(t, err, ErrRedExceedsBlue)
+
+	// 同一明细行重复冲红
+	_, err = PartialRedItems(blue, []*PartialRedLine{{Index: 0}, {Index: 0}}, "", nil)
+	assert.ErrorIs(t, err, ErrRedExceedsBlue)
+
+	_, err = PartialRedItems(blue, []*PartialRedLine{{Index: 0, Num: "2"}, {Index: 0, Num: "2"}}, "", nil)
+	assert.ErrorIs(t, err, ErrRedExceedsBlue)
+
+	_, err = PartialRedItems(blue, []*PartialRedLine{{Index: 0, Num: "2"}, {Index: 0, Num: "1"}}, "", nil)
+	assert.NoError(t, err)
+
+	// 计入该行此前已冲红的金额
+	_, err = PartialRedItems(blue, []*PartialRedLine{{Index: 1, Amount: "53"}}, "53", map[int]string{1: "53"})
+	assert.NoError(t, err)
+
+	_, err = PartialRedItems(blue, []*PartialRedLine{{Index: 1, Amount: "54"}}, "53", map[int]string{1: "53"})
+	assert.ErrorIs(t, err, ErrRedExceedsBlue)
+
+	_, err = PartialRedItems(blue, []*PartialRedLine{{Index: 0, Num: "1"}}, "", map[int]string{0: "-300"})
+	assert.ErrorIs(t, err, ErrRedExceedsBlue)
+}
+
+func TestNewPartialRedReversal_StateDetail(t *testing.T) {
+	c := New("", "", "", "", NewPermanentToken(""))
+	blue := &InvoiceResultItem{SerialNo: "S1", InvoiceKind: InvoiceLineAllElectronicNormal}
+
+	r, err := c.NewPartialRedReversal(&PartialRedRequest{
+		Blue:       blue,
+		BlueDetail: []*GoodsItem{{GoodsName: "A", TaxRate: "0.06", TaxIncludedAmount: "106", WithTaxFlag: "1"}},
+		Lines:      []*PartialRedLine{{Index: 0, Amount: "53"}},
+		Reason:     RedReasonSalesDiscount,
+	})
+	require.NoError(t, err)
+
+	// 明细随状态保存，恢复后仍为部分冲红
+	data, err := json.Marshal(r.State)
+	require.NoError(t, err)
+
+	resumed := NewRedReversal(c, blue, RedReasonSalesDiscount)
+	require.NoError(t, json.Unmarshal(data, &resumed.State))
+	require.Len(t, resumed.State.Detail, 1)
+	assert.Equal(t, "1", resumed.State.Detail[0].BlueDetailIndex)
+	assert.Equal(t, "-53.00", resumed.State.Detail[0].TaxIncludedAmount)
+}
+
+// newPartialRedPlatform 模拟部分冲红接口，快捷冲红订单号重复时返回 E9106
+func newPartialRedPlatform(t *testing.T) (*fakePlatform, *[]*FastInvoiceRedRequest) {
+	p := newFakePlatform(t)
+
+	var mu sync.Mutex
+	reds := []*FastInvoiceRedRequest{}
+	orders := map[string]string{} // 红票订单号 -> 流水号
+
+	p.handle("nuonuo.OpeMplatform.saveInvoiceRedConfirm", func(body []byte) (any, error) {
+		var req SaveInvoiceRedConfirmRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		return req.BillID, nil
+	})
+
+	p.handle("nuonuo.OpeMplatform.queryInvoiceRedConfirm", func(body []byte) (any, error) {
+		var req QueryInvoiceRedConfirmRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		return &QueryInvoiceRedConfirmResponse{Total: 1, List: []*InvoiceRedConfirmItem{
+			{BillNo: "NO-" + req.BillID, BillStatus: RedConfirmStatusConfirmed},
+		}}, nil
+	})
+
+	p.handle("nuonuo.OpeMplatform.fastInvoiceRed", func(body []byte) (any, error) {
+		req := &FastInvoiceRedRequest{}
+		if err := json.Unmarshal(body, req); err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if _, ok := orders[req.OrderNo]; ok {
+			return nil, &Error{Code: "E9106", Msg: "订单号重复"}
+		}
+
+		orders[req.OrderNo] = "RED-" + req.BillNo
+		reds = append(reds, req)
+
+		return &FastInvoiceRedResponse{InvoiceSerialNum: orders[req.OrderNo]}, nil
+	})
+
+	p.handle("nuonuo.OpeMplatform.queryInvoiceResult", func(body []byte) (any, error) {
+		var req QueryInvoiceRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if len(req.OrderNos) > 0 {
+			return []*InvoiceResultItem{{SerialNo: orders[req.OrderNos[0]], OrderNo: req.OrderNos[0]}}, nil
+		}
+
+		return []*InvoiceResultItem{{SerialNo: req.SerialNos[0], Status: InvoiceStatusCompleted}}, nil
+	})
+
+	return p, &reds
+}
+
+func TestNewPartialRedReversal_Twice(t *testing.T) {
+	p, reds := newPartialRedPlatform(t)
+	c := p.client()
+	blue := allElectronicBlue()
+	detail := []*GoodsItem{{GoodsName: "A", TaxRate: "0.06", TaxIncludedAmount: "106", WithTaxFlag: "1"}}
+
+	serialNos := []string{}
+	for i := 0; i < 2; i++ {
+		r, err := c.NewPartialRedReversal(&PartialRedRequest{
+			Blue:            blue,
+			BlueDetail:      detail,
+			Lines:           []*PartialRedLine{{Index: 0, Amount: "50"}},
+			Reason:          RedReasonSalesDiscount,
+			RedAmountIssued: strconv.Itoa(50 * i),
+			LineRedIssued:   map[int]string{0: strconv.Itoa(50 * i)},
+		})
+		require.NoError(t, err)
+
+		r.PollOptions = &WaitOptions{Interval: time.Millisecond}
+
+		item, err := r.Run(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "RED-NO-"+r.State.BillID, item.SerialNo)
+
+		serialNos = append(serialNos, item.SerialNo)
+	}
+
+	// 每次冲红使用不同的红票订单号，均实际申请开具红票
+	require.Len(t, *reds, 2)
+	assert.NotEqual(t, (*reds)[0].OrderNo, (*reds)[1].OrderNo)
+	assert.NotEqual(t, serialNos[0], serialNos[1])
+}
@@ -0,0 +1,84 @@
+package nuonuo
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakePlatform 模拟诺税通开放平台，按接口方法名分发请求
+type fakePlatform struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	handlers map[string]func(body []byte) (any, error)
+	calls    map[string]int
+}
+
+func newFakePlatform(t *testing.T) *fakePlatform {
+	t.Helper()
+
+	p := &fakePlatform{
+		handlers: map[string]func(body []byte) (any, error){},
+		calls:    map[string]int{},
+	}
+
+	p.server = httptest.NewServer(http.HandlerFunc(p.serve))
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// handle 注册接口处理函数，返回 *Error 时响应对应的错误码，返回其他错误时响应 HTTP 500
+func (p *fakePlatform) handle(method string, fn func(body []byte) (any, error)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.handlers[method] = fn
+}
+
+func (p *fakePlatform) count(method string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.calls[method]
+}
+
+func (p *fakePlatform) client() *Client {
+	return New(p.server.URL, "key", "secret", "", NewPermanentToken("token"))
+}
+
+func (p *fakePlatform) serve(w http.ResponseWriter, r *http.Request) {
+	method := r.Header.Get("method")
+
+	p.mu.Lock()
+	p.calls[method]++
+	fn := p.handlers[method]
+	p.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+
+	if fn == nil {
+		http.Error(w, "unknown method "+method, http.StatusNotFound)
+		return
+	}
+
+	result, err := fn(body)
+	if err != nil {
+		var nerr *Error
+		if !errors.As(err, &nerr) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"code": nerr.Code, "describe": nerr.Msg})
+
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]any{"code": "E0000", "describe": "成功", "result": result})
+}
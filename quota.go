@@ -0,0 +1,100 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var ErrQuotaInsufficient = errors.New("credit quota insufficient")
+
+// quotaGuard 开具数电发票前检查剩余授信额度
+type quotaGuard struct {
+	taxNum string
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	remain    *big.Rat
+	expiresAt time.Time
+	refreshes int // 额度刷新次数，刷新前的预占不再归还
+}
+
+// quotaReservation 开票前预占的额度
+type quotaReservation struct {
+	amount  *big.Rat
+	refresh int
+}
+
+// EnableQuotaGuard 开启开票前授信额度检查。
+// 开具数电发票时若价税合计超过剩余额度，OpenInvoice 直接返回 ErrQuotaInsufficient。
+// 剩余额度缓存 ttl 时长，检查通过时即在缓存中预占订单金额，开票请求失败后归还，
+// 并发开票不会同时使用同一部分额度。
+func (c *Client) EnableQuotaGuard(taxNum string, ttl time.Duration) {
+	if taxNum == "" {
+		taxNum = c.userTax
+	}
+
+	c.quotaGuard = &quotaGuard{
+		taxNum: taxNum,
+		ttl:    ttl,
+	}
+}
+
+// reserve 检查订单金额是否超出剩余额度并预占额度；非数电发票返回 nil
+func (g *quotaGuard) reserve(ctx context.Context, c *Client, order *InvoiceOrder) (*quotaReservation, error) {
+	if order == nil || !isAllElectronicLine(order.InvoiceLine) {
+		return nil, nil
+	}
+
+	amount := new(big.Rat)
+	for _, item := range order.InvoiceDetail {
+		_, included, _, err := lineAmounts(item)
+		if err != nil {
+			return nil, err
+		}
+
+		amount.Add(amount, included)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.remain == nil || time.Now().After(g.expiresAt) {
+		resp, err := c.QueryCreditQuota(ctx, &QueryCreditQuotaRequest{TaxNum: g.taxNum})
+		if err != nil {
+			return nil, fmt.Errorf("query credit quota: %w", err)
+		}
+
+		remain, err := parseDecimal(resp.RemainAmount)
+		if err != nil {
+			return nil, fmt.Errorf("remain amount: %w", err)
+		}
+
+		g.remain = remain
+		g.expiresAt = time.Now().Add(g.ttl)
+		g.refreshes++
+	}
+
+	if amount.Cmp(g.remain) > 0 {
+		return nil, fmt.Errorf(
+			"%w: amount %s, remain %s", ErrQuotaInsufficient, formatAmount(amount), formatAmount(g.remain),
+		)
+	}
+
+	g.remain.Sub(g.remain, amount)
+
+	return &quotaReservation{amount: amount, refresh: g.refreshes}, nil
+}
+
+// release 开票失败时归还预占的额度，额度已重新查询时查询结果已是准确值，无需归还
+func (g *quotaGuard) release(r *quotaReservation) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if r.refresh == g.refreshes {
+		g.remain.Add(g.remain, r.amount)
+	}
+}
@@ -0,0 +1,143 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newQuotaPlatform(t *testing.T, remain string) (*fakePlatform, *atomic.Int32) {
+	p := newFakePlatform(t)
+
+	p.handle("nuonuo.OpeMplatform.queryCreditLimit", func(body []byte) (any, error) {
+		return &QueryCreditQuotaResponse{RemainAmount: remain}, nil
+	})
+
+	// 前 failures 次开票返回错误
+	failures := &atomic.Int32{}
+	p.handle("nuonuo.OpeMplatform.requestBillingNew", func(body []byte) (any, error) {
+		time.Sleep(5 * time.Millisecond)
+
+		if failures.Add(-1) >= 0 {
+			return nil, &Error{Code: "E9999", Msg: "开票失败"}
+		}
+
+		return &OpenInvoiceResponse{InvoiceSerialNum: "S1"}, nil
+	})
+
+	return p, failures
+}
+
+func newQuotaRequest(line string) *OpenInvoiceRequest {
+	return &OpenInvoiceRequest{Order: &InvoiceOrder{
+		BuyerName:   "购方名称",
+		SalerTaxNum: "339901999999199",
+		InvoiceType: "1",
+		InvoiceLine: line,
+		InvoiceDetail: []*GoodsItem{
+			{GoodsName: "服务费", TaxRate: "0.06", TaxIncludedAmount: "106", WithTaxFlag: "1"},
+		},
+	}}
+}
+
+func TestClient_QuotaGuard(t *testing.T) {
+	ctx := context.Background()
+	p, _ := newQuotaPlatform(t, "200")
+	c := p.client()
+	c.EnableQuotaGuard("339901999999199", time.Hour)
+
+	_, err := c.OpenInvoice(ctx, newQuotaRequest(InvoiceLineAllElectronicNormal))
+	require.NoError(t, err)
+
+	// 开票成功后扣减缓存中的额度
+	_, err = c.OpenInvoice(ctx, newQuotaRequest(InvoiceLineAllElectronicNormal))
+	assert.ErrorIs(t, err, ErrQuotaInsufficient)
+	assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.requestBillingNew"))
+	assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.queryCreditLimit"))
+
+	// 非数电发票不检查额度
+	_, err = c.OpenInvoice(ctx, newQuotaRequest(InvoiceLineElectronicNormal))
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.queryCreditLimit"))
+}
+
+func TestClient_QuotaGuard_ReleaseOnFailure(t *testing.T) {
+	ctx := context.Background()
+	p, failures := newQuotaPlatform(t, "200")
+	failures.Store(1)
+
+	c := p.client()
+	c.EnableQuotaGuard("", time.Hour)
+
+	var apiErr *Error
+
+	_, err := c.OpenInvoice(ctx, newQuotaRequest(InvoiceLineAllElectronicNormal))
+	require.ErrorAs(t, err, &apiErr)
+
+	// 开票失败后归还预占的额度
+	_, err = c.OpenInvoice(ctx, newQuotaRequest(InvoiceLineAllElectronicNormal))
+	require.NoError(t, err)
+
+	_, err = c.OpenInvoice(ctx, newQuotaRequest(InvoiceLineAllElectronicNormal))
+	assert.ErrorIs(t, err, ErrQuotaInsufficient)
+}
+
+func TestClient_QuotaGuard_Concurrent(t *testing.T) {
+	p, _ := newQuotaPlatform(t, "300")
+	c := p.client()
+	c.EnableQuotaGuard("", time.Hour)
+
+	var (
+		wg           sync.WaitGroup
+		succeeded    atomic.Int32
+		insufficient atomic.Int32
+	)
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := c.OpenInvoice(context.Background(), newQuotaRequest(InvoiceLineAllElectronicNormal))
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, ErrQuotaInsufficient):
+				insufficient.Add(1)
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// 检查时即预占额度，并发开票不超出剩余额度
+	assert.Equal(t, int32(2), succeeded.Load())
+	assert.Equal(t, int32(3), insufficient.Load())
+	assert.Equal(t, 2, p.count("nuonuo.OpeMplatform.requestBillingNew"))
+}
+
+func TestQuotaGuard_ReleaseAfterRefresh(t *testing.T) {
+	p, _ := newQuotaPlatform(t, "200")
+	c := p.client()
+	g := &quotaGuard{ttl: time.Hour}
+
+	r, err := g.reserve(context.Background(), c, newQuotaRequest(InvoiceLineAllElectronicNormal).Order)
+	require.NoError(t, err)
+	assert.Equal(t, "94.00", formatAmount(g.remain))
+
+	// 额度重新查询后不再归还此前的预占
+	g.expiresAt = time.Time{}
+	_, err = g.reserve(context.Background(), c, newQuotaRequest(InvoiceLineAllElectronicNormal).Order)
+	require.NoError(t, err)
+
+	g.release(r)
+	assert.Equal(t, "94.00", formatAmount(g.remain))
+}
@@ -0,0 +1,362 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 冲红原因
+const (
+	RedReasonSalesReturn   = "1" // 销货退回
+	RedReasonIssueError    = "2" // 开票有误
+	RedReasonServiceStop   = "3" // 服务中止
+	RedReasonSalesDiscount = "4" // 销售折让
+)
+
+// 操作方身份（identity、applySource）
+const (
+	IdentitySeller = "0" // 销方
+	IdentityBuyer  = "1" // 购方
+)
+
+// 红字确认单状态
+const (
+	RedConfirmStatusNoNeedConfirm  = "01" // 无需确认
+	RedConfirmStatusBuyerPending   = "02" // 销方录入待购方确认
+	RedConfirmStatusSellerPending  = "03" // 购方录入待销方确认
+	RedConfirmStatusConfirmed      = "04" // 购销双方已确认
+	RedConfirmStatusBuyerRejected  = "05" // 作废（销方录入购方否认）
+	RedConfirmStatusSellerRejected = "06" // 作废（购方录入销方否认）
+	RedConfirmStatusExpired        = "07" // 作废（超72小时未确认）
+	RedConfirmStatusRevoked        = "08" // 作废（发起方已撤销）
+	RedConfirmStatusConfirmRevoked = "09" // 作废（确认后撤销）
+	RedConfirmStatusAbnormal       = "10" // 作废（异常凭证）
+	RedConfirmStatusApplying       = "15" // 申请中
+	RedConfirmStatusApplyFailed    = "16" // 申请失败
+)
+
+// RedReversalStep 冲红流程所处步骤
+type RedReversalStep string
+
+const (
+	RedReversalStepCreated         RedReversalStep = ""                 // 尚未开始
+	RedReversalStepConfirmApplying RedReversalStep = "confirm_applying" // 申请红字确认单
+	RedReversalStepConfirmPending  RedReversalStep = "confirm_pending"  // 等待红字确认单确认
+	RedReversalStepRedIssuing      RedReversalStep = "red_issuing"      // 申请开具红票
+	RedReversalStepRedPending      RedReversalStep = "red_pending"      // 等待红票开具结果
+	RedReversalStepCompleted       RedReversalStep = "completed"        // 冲红完成
+	RedReversalStepFailed          RedReversalStep = "failed"           // 冲红失败
+)
+
+// RedReversalState 冲红流程状态，可序列化保存后用于恢复流程
+type RedReversalState struct {
+	Step         RedReversalStep    `json:"step"`
+	BlueSerialNo string             `json:"blueSerialNo"`
+	OrderNo      string             `json:"orderNo"`     // 红票订单号
+	NeedConfirm  bool               `json:"needConfirm"` // 是否需要红字确认单
+	BillID       string             `json:"billId"`      // 红字确认单申请号
+	BillNo       string             `json:"billNo"`      // 红字确认单编号
+	BillUUID     string             `json:"billUuid"`    // 红字确认单uuid
+	BillStatus   string             `json:"billStatus"`  // 红字确认单状态
+	RedSerialNo  string             `json:"redSerialNo"` // 红票流水号
+	RedInvoice   *InvoiceResultItem `json:"redInvoice"`  // 红票开具结果
+	Error        string             `json:"error"`       // 失败原因
+	UpdatedAt    time.Time          `json:"updatedAt"`
+
+	// 部分冲红明细，为空时全额冲红。随状态保存，恢复流程时不会误按全额冲红
+	Detail []*RedConfirmDetail `json:"detail,omitempty"`
+}
+
+// RedConfirmFailedError 红字确认单被否认、作废或申请失败
+type RedConfirmFailedError struct {
+	BillID      string
+	BillStatus  string
+	BillMessage string
+}
+
+func (e *RedConfirmFailedError) Error() string {
+	return fmt.Sprintf("red confirm %s %s: %s", e.BillID, e.BillStatus, e.BillMessage)
+}
+
+// RedReversal 蓝票冲红流程。
+//
+// 数电发票与税控专票先申请红字确认单，待确认后快捷冲红；税控普票直接快捷冲红。
+// 每一步完成后调用 OnProgress，调用方保存 State 后可在中断时重新执行 Run 继续流程。
+type RedReversal struct {
+	Client *Client
+	Blue   *InvoiceResultItem
+	Reason string // 冲红原因
+
+	State RedReversalState
+
+	// 状态变化时回调，返回错误时中止流程
+	OnProgress func(ctx context.Context, state *RedReversalState) error
+
+	PollOptions *WaitOptions
+}
+
+func NewRedReversal(c *Client, blue *InvoiceResultItem, reason string) *RedReversal {
+	return &RedReversal{
+		Client: c,
+		Blue:   blue,
+		Reason: reason,
+	}
+}
+
+// Run 从当前状态开始推进冲红流程，直到红票开具完成或失败。
+func (r *RedReversal) Run(ctx context.Context) (*InvoiceResultItem, error) {
+	for {
+		var err error
+
+		switch r.State.Step {
+		case RedReversalStepCreated:
+			err = r.start(ctx)
+		case RedReversalStepConfirmApplying:
+			err = r.applyConfirm(ctx)
+		case RedReversalStepConfirmPending:
+			err = r.waitConfirm(ctx)
+		case RedReversalStepRedIssuing:
+			err = r.issueRed(ctx)
+		case RedReversalStepRedPending:
+			err = r.waitRed(ctx)
+		case RedReversalStepCompleted:
+			return r.State.RedInvoice, nil
+		case RedReversalStepFailed:
+			return r.State.RedInvoice, fmt.Errorf("red reversal failed: %s", r.State.Error)
+		default:
+			return nil, fmt.Errorf("unknown red reversal step: %s", r.State.Step)
+		}
+
+		if err != nil {
+			var (
+				confirmErr *RedConfirmFailedError
+				invoiceErr *InvoiceFailedError
+			)
+
+			if errors.As(err, &confirmErr) || errors.As(err, &invoiceErr) || errors.Is(err, ErrInvoiceInvalidated) {
+				r.State.Error = err.Error()
+				if perr := r.transit(ctx, RedReversalStepFailed); perr != nil {
+					return nil, perr
+				}
+			}
+
+			return r.State.RedInvoice, err
+		}
+	}
+}
+
+func (r *RedReversal) transit(ctx context.Context, step RedReversalStep) error {
+	r.State.Step = step
+	r.State.UpdatedAt = time.Now()
+
+	if r.OnProgress != nil {
+		return r.OnProgress(ctx, &r.State)
+	}
+
+	return nil
+}
+
+func (r *RedReversal) isAllElectronic() bool {
+	return isAllElectronicLine(r.Blue.InvoiceKind) || r.Blue.AllElectronicInvoiceNumbe != ""
+}
+
+func (r *RedReversal) start(ctx context.Context) error {
+	r.State.BlueSerialNo = r.Blue.SerialNo
+	if r.State.OrderNo == "" {
+		r.State.OrderNo = redOrderNo(r.Blue)
+	}
+
+	r.State.NeedConfirm = r.isAllElectronic() || isSpecialLine(r.Blue.InvoiceKind)
+	if !r.State.NeedConfirm {
+		return r.transit(ctx, RedReversalStepRedIssuing)
+	}
+
+	if r.State.BillID == "" {
+		r.State.BillID = newBillID()
+	}
+
+	return r.transit(ctx, RedReversalStepConfirmApplying)
+}
+
+func (r *RedReversal) applyConfirm(ctx context.Context) error {
+	req := &SaveInvoiceRedConfirmRequest{
+		BillID:          r.State.BillID,
+		BlueInvoiceLine: r.Blue.InvoiceKind,
+		ApplySource:     IdentitySeller,
+		SellerTaxNo:     r.Blue.SalerTaxNum,
+		SellerName:      r.Blue.SaleName,
+		BuyerTaxNo:      r.Blue.PayerTaxNo,
+		BuyerName:       r.Blue.PayerName,
+		RedReason:       r.Reason,
+		DepartmentID:    r.Blue.DeptID,
+		ClerkID:         r.Blue.ClerkID,
+		ExtensionNumber: r.Blue.ExtensionNumber,
+		OrderNo:         r.State.OrderNo,
+		Detail:          r.State.Detail,
+	}
+
+	id, err := InvoiceIdentityOf(r.Blue)
+	if err != nil {
+		return err
+	}
+
+	id.ApplyToRedConfirm(req)
+
+	if _, err := r.Client.SaveInvoiceRedConfirm(ctx, req); err != nil {
+		// 申请号重复说明上次申请已成功，继续查询确认状态
+		var nerr *Error
+		if !errors.As(err, &nerr) || !nerr.IsDuplicateOrderNo() {
+			return err
+		}
+	}
+
+	return r.transit(ctx, RedReversalStepConfirmPending)
+}
+
+func (r *RedReversal) waitConfirm(ctx context.Context) error {
+	opts := r.PollOptions.withDefaults()
+	interval := opts.Interval
+
+	var lastErr error
+
+	for {
+		resp, err := r.Client.QueryInvoiceRedConfirm(ctx, &QueryInvoiceRedConfirmRequest{
+			Identity: IdentitySeller,
+			BillID:   r.State.BillID,
+		})
+
+		// 接口返回的错误直接返回，网络等临时错误继续轮询
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			return err
+		}
+
+		lastErr = err
+
+		if err == nil && len(resp.List) > 0 {
+			item := resp.List[0]
+
+			if item.BillStatus != r.State.BillStatus {
+				r.State.BillStatus = item.BillStatus
+				r.State.BillNo = item.BillNo
+				r.State.BillUUID = item.BillUUID
+
+				if err := r.transit(ctx, RedReversalStepConfirmPending); err != nil {
+					return err
+				}
+			}
+
+			switch item.BillStatus {
+			case RedConfirmStatusNoNeedConfirm, RedConfirmStatusConfirmed:
+				return r.transit(ctx, RedReversalStepRedIssuing)
+			case RedConfirmStatusBuyerRejected, RedConfirmStatusSellerRejected, RedConfirmStatusExpired,
+				RedConfirmStatusRevoked, RedConfirmStatusConfirmRevoked, RedConfirmStatusAbnormal,
+				RedConfirmStatusApplyFailed:
+				return &RedConfirmFailedError{
+					BillID:      r.State.BillID,
+					BillStatus:  item.BillStatus,
+					BillMessage: item.BillMessage,
+				}
+			}
+		}
+
+		if err := sleepContext(ctx, interval); err != nil {
+			if lastErr != nil {
+				return fmt.Errorf("wait red confirm %s: %w: last error: %w", r.State.BillID, err, lastErr)
+			}
+
+			return fmt.Errorf("wait red confirm %s: %w", r.State.BillID, err)
+		}
+
+		interval = opts.next(interval)
+	}
+}
+
+func (r *RedReversal) issueRed(ctx context.Context) error {
+	req := &FastInvoiceRedRequest{
+		OrderNo:         r.State.OrderNo,
+		ExtensionNumber: r.Blue.ExtensionNumber,
+		ClerkID:         r.Blue.ClerkID,
+		DeptID:          r.Blue.DeptID,
+		TaxNum:          r.Blue.SalerTaxNum,
+		InvoiceID:       r.Blue.SerialNo,
+		BillNo:          r.State.BillNo,
+		BillUUID:        r.State.BillUUID,
+		InvoiceLine:     r.Blue.InvoiceKind,
+	}
+
+	id, err := InvoiceIdentityOf(r.Blue)
+	if err != nil {
+		return err
+	}
+
+	id.ApplyToFastRed(req)
+
+	resp, err := r.Client.FastInvoiceRed(ctx, req)
+	if err != nil {
+		var nerr *Error
+		if !errors.As(err, &nerr) || !nerr.IsDuplicateOrderNo() {
+			return err
+		}
+
+		// 订单号重复说明上次申请已成功，按订单号找回红票流水号
+		serialNo, err := r.findRedSerialNo(ctx)
+		if err != nil {
+			return err
+		}
+
+		r.State.RedSerialNo = serialNo
+	} else {
+		r.State.RedSerialNo = resp.InvoiceSerialNum
+	}
+
+	return r.transit(ctx, RedReversalStepRedPending)
+}
+
+func (r *RedReversal) findRedSerialNo(ctx context.Context) (string, error) {
+	items, err := r.Client.QueryInvoice(ctx, &QueryInvoiceRequest{OrderNos: []string{r.State.OrderNo}})
+	if err != nil {
+		return "", err
+	}
+
+	for _, item := range items {
+		if item.OrderNo == r.State.OrderNo {
+			return item.SerialNo, nil
+		}
+	}
+
+	return "", fmt.Errorf("red invoice of order %s not found", r.State.OrderNo)
+}
+
+func (r *RedReversal) waitRed(ctx context.Context) error {
+	item, err := r.Client.WaitForInvoice(ctx, r.State.RedSerialNo, r.PollOptions)
+	if item != nil {
+		r.State.RedInvoice = item
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return r.transit(ctx, RedReversalStepCompleted)
+}
+
+// newBillID 生成红字确认单申请号
+func newBillID() string {
+	return strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
+// redOrderNo 由蓝票订单号生成红票订单号
+func redOrderNo(blue *InvoiceResultItem) string {
+	base := blue.OrderNo
+	if base == "" {
+		base = blue.SerialNo
+	}
+
+	return base + "R"
+}
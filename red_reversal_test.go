@@ -0,0 +1,288 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redPlatform 模拟冲红相关接口
+type redPlatform struct {
+	*fakePlatform
+
+	mu             sync.Mutex
+	billStatuses   []string // 依次返回的红字确认单状态，最后一个重复返回
+	saveDuplicate  bool     // 申请红字确认单返回申请号重复
+	redDuplicate   bool     // 快捷冲红返回订单号重复
+	queryFailures  int      // 查询红字确认单前 queryFailures 次返回 HTTP 500
+	confirmRequest *SaveInvoiceRedConfirmRequest
+	redRequest     *FastInvoiceRedRequest
+}
+
+func newRedPlatform(t *testing.T, billStatuses ...string) *redPlatform {
+	p := &redPlatform{fakePlatform: newFakePlatform(t), billStatuses: billStatuses}
+
+	p.handle("nuonuo.OpeMplatform.saveInvoiceRedConfirm", func(body []byte) (any, error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.confirmRequest = &SaveInvoiceRedConfirmRequest{}
+		if err := json.Unmarshal(body, p.confirmRequest); err != nil {
+			return nil, err
+		}
+
+		if p.saveDuplicate {
+			return nil, &Error{Code: "E9106", Msg: "申请号重复"}
+		}
+
+		return p.confirmRequest.BillID, nil
+	})
+
+	p.handle("nuonuo.OpeMplatform.queryInvoiceRedConfirm", func(body []byte) (any, error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if p.queryFailures > 0 {
+			p.queryFailures--
+			return nil, errors.New("service unavailable")
+		}
+
+		status := p.billStatuses[0]
+		if len(p.billStatuses) > 1 {
+			p.billStatuses = p.billStatuses[1:]
+		}
+
+		return &QueryInvoiceRedConfirmResponse{
+			Total: 1,
+			List: []*InvoiceRedConfirmItem{
+				{BillNo: "BILLNO", BillUUID: "BILLUUID", BillStatus: status, BillMessage: "msg-" + status},
+			},
+		}, nil
+	})
+
+	p.handle("nuonuo.OpeMplatform.fastInvoiceRed", func(body []byte) (any, error) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.redRequest = &FastInvoiceRedRequest{}
+		if err := json.Unmarshal(body, p.redRequest); err != nil {
+			return nil, err
+		}
+
+		if p.redDuplicate {
+			return nil, &Error{Code: "E9106", Msg: "订单号重复"}
+		}
+
+		return &FastInvoiceRedResponse{InvoiceSerialNum: "RED1"}, nil
+	})
+
+	p.handle("nuonuo.OpeMplatform.queryInvoiceResult", func(body []byte) (any, error) {
+		var req QueryInvoiceRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		if len(req.OrderNos) > 0 {
+			return []*InvoiceResultItem{{SerialNo: "RED1", OrderNo: req.OrderNos[0], Status: InvoiceStatusIssuing}}, nil
+		}
+
+		return []*InvoiceResultItem{{SerialNo: req.SerialNos[0], Status: InvoiceStatusCompleted}}, nil
+	})
+
+	return p
+}
+
+func newTestRedReversal(p *redPlatform, blue *InvoiceResultItem) (*RedReversal, *[]RedReversalStep) {
+	r := NewRedReversal(p.client(), blue, RedReasonIssueError)
+	r.PollOptions = &WaitOptions{Interval: time.Millisecond}
+
+	steps := []RedReversalStep{}
+	r.OnProgress = func(ctx context.Context, state *RedReversalState) error {
+		if len(steps) == 0 || steps[len(steps)-1] != state.Step {
+			steps = append(steps, state.Step)
+		}
+
+		return nil
+	}
+
+	return r, &steps
+}
+
+func allElectronicBlue() *InvoiceResultItem {
+	return &InvoiceResultItem{
+		SerialNo:                  "BLUE1",
+		OrderNo:                   "ORDER1",
+		InvoiceKind:               InvoiceLineAllElectronicNormal,
+		AllElectronicInvoiceNumbe: "24332000000012345678",
+		SalerTaxNum:               "339901999999199",
+	}
+}
+
+func TestRedReversal_AllElectronic(t *testing.T) {
+	p := newRedPlatform(t, RedConfirmStatusApplying, RedConfirmStatusConfirmed)
+	r, steps := newTestRedReversal(p, allElectronicBlue())
+
+	item, err := r.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "RED1", item.SerialNo)
+
+	assert.Equal(t, []RedReversalStep{
+		RedReversalStepConfirmApplying,
+		RedReversalStepConfirmPending,
+		RedReversalStepRedIssuing,
+		RedReversalStepRedPending,
+		RedReversalStepCompleted,
+	}, *steps)
+
+	assert.Equal(t, "24332000000012345678", p.confirmRequest.BlueElecInvoiceNumber)
+	assert.Equal(t, "ORDER1R", p.confirmRequest.OrderNo)
+	assert.Equal(t, "BILLNO", p.redRequest.BillNo)
+	assert.Equal(t, "24332000000012345678", p.redRequest.ElecInvoiceNumber)
+	assert.Equal(t, RedConfirmStatusConfirmed, r.State.BillStatus)
+}
+
+func TestRedReversal_ConfirmRejected(t *testing.T) {
+	p := newRedPlatform(t, RedConfirmStatusBuyerPending, RedConfirmStatusBuyerRejected)
+	r, _ := newTestRedReversal(p, allElectronicBlue())
+
+	_, err := r.Run(context.Background())
+
+	var confirmErr *RedConfirmFailedError
+	require.ErrorAs(t, err, &confirmErr)
+	assert.Equal(t, RedConfirmStatusBuyerRejected, confirmErr.BillStatus)
+	assert.Equal(t, RedReversalStepFailed, r.State.Step)
+	assert.NotEmpty(t, r.State.Error)
+	assert.Zero(t, p.count("nuonuo.OpeMplatform.fastInvoiceRed"))
+
+	// 失败状态再次执行直接返回错误
+	_, err = r.Run(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.saveInvoiceRedConfirm"))
+}
+
+func TestRedReversal_ConfirmQueryRetry(t *testing.T) {
+	p := newRedPlatform(t, RedConfirmStatusConfirmed)
+	p.queryFailures = 2
+	r, _ := newTestRedReversal(p, allElectronicBlue())
+
+	// 查询确认单的临时错误继续轮询
+	item, err := r.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "RED1", item.SerialNo)
+	assert.Equal(t, 3, p.count("nuonuo.OpeMplatform.queryInvoiceRedConfirm"))
+
+	// 超时时返回最后一次错误，保留状态以便恢复
+	p = newRedPlatform(t, RedConfirmStatusConfirmed)
+	p.queryFailures = 1 << 20
+	r, _ = newTestRedReversal(p, allElectronicBlue())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err = r.Run(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "last error")
+	assert.Equal(t, RedReversalStepConfirmPending, r.State.Step)
+}
+
+func TestRedReversal_DuplicateRecovery(t *testing.T) {
+	p := newRedPlatform(t, RedConfirmStatusConfirmed)
+	p.saveDuplicate = true
+	p.redDuplicate = true
+
+	r, _ := newTestRedReversal(p, allElectronicBlue())
+
+	item, err := r.Run(context.Background())
+	require.NoError(t, err)
+
+	// 冲红订单号重复时按订单号找回红票流水号
+	assert.Equal(t, "RED1", r.State.RedSerialNo)
+	assert.Equal(t, InvoiceStatusCompleted, item.Status)
+	assert.Equal(t, RedReversalStepCompleted, r.State.Step)
+}
+
+func TestRedReversal_Resume(t *testing.T) {
+	cases := []struct {
+		step  RedReversalStep
+		saves int
+		reds  int
+	}{
+		{RedReversalStepConfirmApplying, 1, 1},
+		{RedReversalStepConfirmPending, 0, 1},
+		{RedReversalStepRedIssuing, 0, 1},
+		{RedReversalStepRedPending, 0, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.step), func(t *testing.T) {
+			p := newRedPlatform(t, RedConfirmStatusConfirmed)
+			r, _ := newTestRedReversal(p, allElectronicBlue())
+
+			// 使用保存的状态恢复流程
+			saved := RedReversalState{
+				Step:         tc.step,
+				BlueSerialNo: "BLUE1",
+				OrderNo:      "ORDER1R",
+				NeedConfirm:  true,
+				BillID:       "BILLID",
+				BillNo:       "BILLNO",
+				BillUUID:     "BILLUUID",
+				Detail:       []*RedConfirmDetail{{BlueDetailIndex: "1", TaxIncludedAmount: "-10.00"}},
+			}
+			if tc.step == RedReversalStepRedPending {
+				saved.RedSerialNo = "RED1"
+			}
+
+			data, err := json.Marshal(saved)
+			require.NoError(t, err)
+			require.NoError(t, json.Unmarshal(data, &r.State))
+
+			item, err := r.Run(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "RED1", item.SerialNo)
+			assert.Equal(t, RedReversalStepCompleted, r.State.Step)
+
+			assert.Equal(t, tc.saves, p.count("nuonuo.OpeMplatform.saveInvoiceRedConfirm"))
+			assert.Equal(t, tc.reds, p.count("nuonuo.OpeMplatform.fastInvoiceRed"))
+
+			if tc.saves > 0 {
+				assert.Equal(t, "BILLID", p.confirmRequest.BillID)
+				assert.Len(t, p.confirmRequest.Detail, 1, "partial detail survives resume")
+			}
+		})
+	}
+}
+
+func TestRedReversal_LegacyNormalSkipsConfirm(t *testing.T) {
+	p := newRedPlatform(t, RedConfirmStatusConfirmed)
+	r, steps := newTestRedReversal(p, &InvoiceResultItem{
+		SerialNo:    "BLUE1",
+		OrderNo:     "ORDER1",
+		InvoiceKind: InvoiceLineElectronicNormal,
+		InvoiceCode: "033002000111",
+		InvoiceNo:   "12345678",
+	})
+
+	_, err := r.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.False(t, r.State.NeedConfirm)
+	assert.Equal(t, []RedReversalStep{
+		RedReversalStepRedIssuing,
+		RedReversalStepRedPending,
+		RedReversalStepCompleted,
+	}, *steps)
+	assert.Zero(t, p.count("nuonuo.OpeMplatform.saveInvoiceRedConfirm"))
+	assert.Zero(t, p.count("nuonuo.OpeMplatform.queryInvoiceRedConfirm"))
+
+	assert.Equal(t, "033002000111", p.redRequest.InvoiceCode)
+	assert.Equal(t, "12345678", p.redRequest.InvoiceNumber)
+	assert.Empty(t, p.redRequest.ElecInvoiceNumber)
+	assert.Empty(t, p.redRequest.BillNo)
+}
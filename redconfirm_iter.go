@@ -0,0 +1,143 @@
+package nuonuo
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+const redConfirmMaxPageSize = 50
+
+// RedConfirmFilter 红字确认单查询条件
+type RedConfirmFilter struct {
+	Identity      string    // 操作方身份，默认 IdentitySeller
+	BillStatus    string    // 红字确认单状态（为空则查全部状态）
+	BillTimeStart time.Time // 填开起始时间
+	BillTimeEnd   time.Time // 填开结束时间
+	PageSize      int       // 每页数量，默认且最大为50
+}
+
+// RedConfirmIterator 按页惰性获取红字确认单
+//
+//	it := c.RedConfirmForms(ctx, filter)
+//	for it.Next() {
+//		item := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type RedConfirmIterator struct {
+	ctx    context.Context
+	client *Client
+	req    QueryInvoiceRedConfirmRequest
+
+	pageSize int
+	pageNo   int
+	page     []*InvoiceRedConfirmItem
+	pos      int
+	fetched  int
+	total    int
+	done     bool
+	item     *InvoiceRedConfirmItem
+	err      error
+}
+
+// RedConfirmForms 遍历满足条件的红字确认单，filter 为 nil 时查询销方的全部红字确认单
+func (c *Client) RedConfirmForms(ctx context.Context, filter *RedConfirmFilter) *RedConfirmIterator {
+	if filter == nil {
+		filter = &RedConfirmFilter{}
+	}
+
+	identity := filter.Identity
+	if identity == "" {
+		identity = IdentitySeller
+	}
+
+	pageSize := filter.PageSize
+	if pageSize <= 0 || pageSize > redConfirmMaxPageSize {
+		pageSize = redConfirmMaxPageSize
+	}
+
+	req := QueryInvoiceRedConfirmRequest{
+		Identity:   identity,
+		BillStatus: filter.BillStatus,
+		PageSize:   strconv.Itoa(pageSize),
+	}
+
+	if !filter.BillTimeStart.IsZero() {
+		req.BillTimeStart = NewTime(filter.BillTimeStart).String()
+	}
+
+	if !filter.BillTimeEnd.IsZero() {
+		req.BillTimeEnd = NewTime(filter.BillTimeEnd).String()
+	}
+
+	return &RedConfirmIterator{
+		ctx:      ctx,
+		client:   c,
+		req:      req,
+		pageSize: pageSize,
+	}
+}
+
+// Next 移动到下一条记录，没有更多记录或出错时返回 false
+func (it *RedConfirmIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.pos >= len(it.page) {
+		if it.done {
+			return false
+		}
+
+		if err := it.fetch(); err != nil {
+			it.err = err
+			return false
+		}
+
+		if len(it.page) == 0 {
+			return false
+		}
+	}
+
+	it.item = it.page[it.pos]
+	it.pos++
+
+	return true
+}
+
+func (it *RedConfirmIterator) fetch() error {
+	it.pageNo++
+	it.req.PageNo = strconv.Itoa(it.pageNo)
+
+	resp, err := it.client.QueryInvoiceRedConfirm(it.ctx, &it.req)
+	if err != nil {
+		return err
+	}
+
+	it.page = resp.List
+	it.pos = 0
+	it.total = resp.Total
+	it.fetched += len(resp.List)
+
+	if it.fetched >= it.total || len(resp.List) < it.pageSize {
+		it.done = true
+	}
+
+	return nil
+}
+
+// Item 返回当前记录
+func (it *RedConfirmIterator) Item() *InvoiceRedConfirmItem {
+	return it.item
+}
+
+// Err 返回遍历过程中的错误
+func (it *RedConfirmIterator) Err() error {
+	return it.err
+}
+
+// Total 返回平台报告的记录总数，获取第一页之前为0
+func (it *RedConfirmIterator) Total() int {
+	return it.total
+}
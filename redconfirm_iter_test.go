@@ -0,0 +1,115 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newRedConfirmPagePlatform 按 pages 依次返回每页的记录数，total 为报告的总数
+func newRedConfirmPagePlatform(
+	t *testing.T, total int, pages ...int,
+) (*fakePlatform, *[]*QueryInvoiceRedConfirmRequest) {
+	p := newFakePlatform(t)
+
+	var mu sync.Mutex
+	requests := []*QueryInvoiceRedConfirmRequest{}
+
+	p.handle("nuonuo.OpeMplatform.queryInvoiceRedConfirm", func(body []byte) (any, error) {
+		req := &QueryInvoiceRedConfirmRequest{}
+		if err := json.Unmarshal(body, req); err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, req)
+
+		list := []*InvoiceRedConfirmItem{}
+		if n := len(requests) - 1; n < len(pages) {
+			for i := 0; i < pages[n]; i++ {
+				list = append(list, &InvoiceRedConfirmItem{BillNo: req.PageNo + "-" + strconv.Itoa(i)})
+			}
+		}
+
+		return &QueryInvoiceRedConfirmResponse{Total: total, List: list}, nil
+	})
+
+	return p, &requests
+}
+
+func collectRedConfirmForms(it *RedConfirmIterator) []string {
+	billNos := []string{}
+	for it.Next() {
+		billNos = append(billNos, it.Item().BillNo)
+	}
+
+	return billNos
+}
+
+func TestClient_RedConfirmForms_StopAtTotal(t *testing.T) {
+	p, requests := newRedConfirmPagePlatform(t, 4, 2, 2, 2)
+
+	it := p.client().RedConfirmForms(context.Background(), &RedConfirmFilter{
+		Identity: IdentityBuyer, BillStatus: RedConfirmStatusBuyerPending, PageSize: 2,
+	})
+
+	assert.Equal(t, []string{"1-0", "1-1", "2-0", "2-1"}, collectRedConfirmForms(it))
+	require.NoError(t, it.Err())
+	assert.Equal(t, 4, it.Total())
+
+	// 已获取的记录数达到总数后不再请求下一页
+	require.Len(t, *requests, 2)
+	for i, req := range *requests {
+		assert.Equal(t, strconv.Itoa(i+1), req.PageNo)
+		assert.Equal(t, "2", req.PageSize)
+		assert.Equal(t, IdentityBuyer, req.Identity)
+		assert.Equal(t, RedConfirmStatusBuyerPending, req.BillStatus)
+	}
+}
+
+func TestClient_RedConfirmForms_StopAtShortPage(t *testing.T) {
+	p, requests := newRedConfirmPagePlatform(t, 100, 2, 1, 2)
+
+	it := p.client().RedConfirmForms(context.Background(), &RedConfirmFilter{PageSize: 2})
+
+	// 返回记录数少于每页数量时视为最后一页
+	assert.Equal(t, []string{"1-0", "1-1", "2-0"}, collectRedConfirmForms(it))
+	require.NoError(t, it.Err())
+	assert.Len(t, *requests, 2)
+}
+
+func TestClient_RedConfirmForms_NilFilter(t *testing.T) {
+	p, requests := newRedConfirmPagePlatform(t, 0)
+
+	it := p.client().RedConfirmForms(context.Background(), nil)
+
+	assert.Empty(t, collectRedConfirmForms(it))
+	require.NoError(t, it.Err())
+	require.Len(t, *requests, 1)
+	assert.Equal(t, IdentitySeller, (*requests)[0].Identity)
+	assert.Equal(t, strconv.Itoa(redConfirmMaxPageSize), (*requests)[0].PageSize)
+}
+
+func TestClient_RedConfirmForms_Error(t *testing.T) {
+	p := newFakePlatform(t)
+	p.handle("nuonuo.OpeMplatform.queryInvoiceRedConfirm", func(body []byte) (any, error) {
+		return nil, &Error{Code: "E9999", Msg: "查询失败"}
+	})
+
+	it := p.client().RedConfirmForms(context.Background(), nil)
+
+	assert.False(t, it.Next())
+	assert.False(t, it.Next())
+
+	var apiErr *Error
+	require.ErrorAs(t, it.Err(), &apiErr)
+	assert.Equal(t, "E9999", apiErr.Code)
+	assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.queryInvoiceRedConfirm"))
+}
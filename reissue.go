@@ -0,0 +1,291 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const defaultReissueAttempts = 3
+
+// ReissueStep 换开流程所处步骤
+type ReissueStep string
+
+const (
+	ReissueStepCreated   ReissueStep = ""          // 尚未开始
+	ReissueStepReversing ReissueStep = "reversing" // 冲红蓝票
+	ReissueStepIssuing   ReissueStep = "issuing"   // 申请开具新票
+	ReissueStepPending   ReissueStep = "pending"   // 等待新票开具结果
+	ReissueStepCompleted ReissueStep = "completed" // 换开完成
+	ReissueStepFailed    ReissueStep = "failed"    // 换开失败，需人工处理
+)
+
+// ReissueState 换开流程状态
+type ReissueState struct {
+	ID          string             `json:"id"` // 蓝票流水号
+	Step        ReissueStep        `json:"step"`
+	Reversal    RedReversalState   `json:"reversal"`
+	Attempts    int                `json:"attempts"`    // 新票开具次数
+	OrderNo     string             `json:"orderNo"`     // 新票订单号
+	NewSerialNo string             `json:"newSerialNo"` // 新票流水号
+	NewInvoice  *InvoiceResultItem `json:"newInvoice"`  // 新票开具结果
+	Error       string             `json:"error"`       // 失败原因
+	UpdatedAt   time.Time          `json:"updatedAt"`
+}
+
+// ReissueStore 保存换开流程状态
+type ReissueStore interface {
+	// Load 读取流程状态，不存在时返回 nil, nil
+	Load(ctx context.Context, id string) (*ReissueState, error)
+	Save(ctx context.Context, state *ReissueState) error
+}
+
+type memoryReissueStore struct {
+	mu     sync.Mutex
+	states map[string]ReissueState
+}
+
+// NewMemoryReissueStore 基于内存的流程状态存储，进程退出后状态丢失，仅用于测试
+func NewMemoryReissueStore() ReissueStore {
+	return &memoryReissueStore{
+		states: map[string]ReissueState{},
+	}
+}
+
+func (s *memoryReissueStore) Load(ctx context.Context, id string) (*ReissueState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &state, nil
+}
+
+func (s *memoryReissueStore) Save(ctx context.Context, state *ReissueState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[state.ID] = *state
+
+	return nil
+}
+
+// ReissueFailedError 红冲已完成但新票多次开具失败，或新票开具后被作废。
+// 此时蓝票已冲红、没有有效的新票，需人工处理，如修正开票信息后手动开具。
+type ReissueFailedError struct {
+	State *ReissueState
+}
+
+func (e *ReissueFailedError) Error() string {
+	return fmt.Sprintf("reissue %s failed after %d attempts: %s", e.State.ID, e.State.Attempts, e.State.Error)
+}
+
+type ReissueRequest struct {
+	Blue   *InvoiceResultItem
+	Order  *InvoiceOrder // 更正后的开票信息，订单号由流程生成
+	Reason string        // 冲红原因，默认开票有误
+
+	Store       ReissueStore
+	MaxAttempts int // 新票最多开具次数，默认3
+	PollOptions *WaitOptions
+
+	// 状态保存后回调
+	OnProgress func(ctx context.Context, state *ReissueState)
+}
+
+// ReissueInvoice 换开发票：冲红蓝票后按更正后的信息重新开具。
+//
+// 每一步的状态都写入 Store，流程中断后以相同参数再次调用即从中断处继续。
+// 新票开具失败时以新的订单号重新开具，超过 MaxAttempts 后返回 *ReissueFailedError。
+//
+// 红字发票开具后无法撤销，流程不支持回滚：冲红完成后唯一的补偿方式是重新开具新票，
+// 冲红之前失败的流程不影响蓝票。
+func (c *Client) ReissueInvoice(ctx context.Context, req *ReissueRequest) (*InvoiceResultItem, error) {
+	if req.Store == nil {
+		return nil, errors.New("reissue store required")
+	}
+
+	state, err := req.Store.Load(ctx, req.Blue.SerialNo)
+	if err != nil {
+		return nil, fmt.Errorf("load reissue state: %w", err)
+	}
+
+	if state == nil {
+		state = &ReissueState{ID: req.Blue.SerialNo}
+	}
+
+	s := &reissueSaga{client: c, req: req, state: state}
+
+	return s.run(ctx)
+}
+
+type reissueSaga struct {
+	client *Client
+	req    *ReissueRequest
+	state  *ReissueState
+}
+
+func (s *reissueSaga) run(ctx context.Context) (*InvoiceResultItem, error) {
+	for {
+		var err error
+
+		switch s.state.Step {
+		case ReissueStepCreated:
+			err = s.transit(ctx, ReissueStepReversing)
+		case ReissueStepReversing:
+			err = s.reverse(ctx)
+		case ReissueStepIssuing:
+			err = s.issue(ctx)
+		case ReissueStepPending:
+			err = s.wait(ctx)
+		case ReissueStepCompleted:
+			return s.state.NewInvoice, nil
+		case ReissueStepFailed:
+			return nil, &ReissueFailedError{State: s.state}
+		default:
+			return nil, fmt.Errorf("unknown reissue step: %s", s.state.Step)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *reissueSaga) transit(ctx context.Context, step ReissueStep) error {
+	s.state.Step = step
+	s.state.UpdatedAt = time.Now()
+
+	if err := s.req.Store.Save(ctx, s.state); err != nil {
+		return fmt.Errorf("save reissue state: %w", err)
+	}
+
+	if s.req.OnProgress != nil {
+		s.req.OnProgress(ctx, s.state)
+	}
+
+	return nil
+}
+
+func (s *reissueSaga) reverse(ctx context.Context) error {
+	reason := s.req.Reason
+	if reason == "" {
+		reason = RedReasonIssueError
+	}
+
+	r := NewRedReversal(s.client, s.req.Blue, reason)
+	r.State = s.state.Reversal
+	r.PollOptions = s.req.PollOptions
+	r.OnProgress = func(ctx context.Context, state *RedReversalState) error {
+		s.state.Reversal = *state
+		return s.transit(ctx, ReissueStepReversing)
+	}
+
+	_, err := r.Run(ctx)
+	if err != nil {
+		// 冲红失败时蓝票仍有效，无需补偿
+		if r.State.Step == RedReversalStepFailed {
+			s.state.Error = err.Error()
+			if serr := s.transit(ctx, ReissueStepFailed); serr != nil {
+				return serr
+			}
+		}
+
+		return err
+	}
+
+	return s.nextAttempt(ctx)
+}
+
+// nextAttempt 以新的订单号开具新票
+func (s *reissueSaga) nextAttempt(ctx context.Context) error {
+	maxAttempts := s.req.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultReissueAttempts
+	}
+
+	if s.state.Attempts >= maxAttempts {
+		return s.transit(ctx, ReissueStepFailed)
+	}
+
+	s.state.Attempts++
+	s.state.OrderNo = reissueOrderNo(s.req.Blue, s.state.Attempts)
+	s.state.NewSerialNo = ""
+	s.state.NewInvoice = nil
+
+	return s.transit(ctx, ReissueStepIssuing)
+}
+
+func (s *reissueSaga) issue(ctx context.Context) error {
+	order := *s.req.Order
+	order.OrderNo = s.state.OrderNo
+
+	resp, err := s.client.OpenInvoice(ctx, &OpenInvoiceRequest{Order: &order})
+	if err != nil {
+		var nerr *Error
+		if !errors.As(err, &nerr) || !nerr.IsDuplicateOrderNo() {
+			return err
+		}
+
+		// 订单号重复说明上次请求已成功，按订单号找回流水号
+		items, err := s.client.QueryInvoice(ctx, &QueryInvoiceRequest{OrderNos: []string{order.OrderNo}})
+		if err != nil {
+			return err
+		}
+
+		if len(items) == 0 {
+			return fmt.Errorf("invoice of order %s not found", order.OrderNo)
+		}
+
+		s.state.NewSerialNo = items[0].SerialNo
+	} else {
+		s.state.NewSerialNo = resp.InvoiceSerialNum
+	}
+
+	return s.transit(ctx, ReissueStepPending)
+}
+
+func (s *reissueSaga) wait(ctx context.Context) error {
+	item, err := s.client.WaitForInvoice(ctx, s.state.NewSerialNo, s.req.PollOptions)
+	if errors.Is(err, ErrInvoiceInvalidated) {
+		// 新票已开具后被作废，不再自动重开，需人工处理
+		s.state.NewInvoice = item
+		s.state.Error = err.Error()
+
+		return s.transit(ctx, ReissueStepFailed)
+	}
+
+	// 签章失败的新票已开具，不能重新开具，否则购方会收到两张有效发票
+	if err != nil {
+		var ferr *InvoiceFailedError
+		if !errors.As(err, &ferr) {
+			return err
+		}
+
+		// 新票开具失败时补偿：换新的订单号重新开具
+		s.state.Error = err.Error()
+
+		return s.nextAttempt(ctx)
+	}
+
+	s.state.NewInvoice = item
+	s.state.Error = ""
+
+	return s.transit(ctx, ReissueStepCompleted)
+}
+
+// reissueOrderNo 由蓝票订单号生成换开新票的订单号
+func reissueOrderNo(blue *InvoiceResultItem, attempt int) string {
+	base := blue.OrderNo
+	if base == "" {
+		base = blue.SerialNo
+	}
+
+	return base + "C" + strconv.Itoa(attempt)
+}
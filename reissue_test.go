@@ -0,0 +1,209 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reissuePlatform 模拟冲红与开票接口，新票按 failures 次数开具失败
+type reissuePlatform struct {
+	*fakePlatform
+
+	mu         sync.Mutex
+	failures   int
+	failStatus InvoiceStatus     // 新票开具失败时返回的状态，默认开票失败
+	orders     map[string]string // 订单号 -> 流水号
+	orderNos   []string          // 依次申请开具的订单号
+}
+
+func newReissuePlatform(t *testing.T, failures int) *reissuePlatform {
+	p := &reissuePlatform{
+		fakePlatform: newFakePlatform(t),
+		failures:     failures,
+		failStatus:   InvoiceStatusFailed,
+		orders:       map[string]string{},
+	}
+
+	p.handle("nuonuo.OpeMplatform.fastInvoiceRed", func(body []byte) (any, error) {
+		return &FastInvoiceRedResponse{InvoiceSerialNum: "RED1"}, nil
+	})
+
+	p.handle("nuonuo.OpeMplatform.requestBillingNew", func(body []byte) (any, error) {
+		var req OpenInvoiceRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if _, ok := p.orders[req.Order.OrderNo]; ok {
+			return nil, &Error{Code: "E9106", Msg: "订单号重复"}
+		}
+
+		serialNo := "NEW-" + req.Order.OrderNo
+		p.orders[req.Order.OrderNo] = serialNo
+		p.orderNos = append(p.orderNos, req.Order.OrderNo)
+
+		return &OpenInvoiceResponse{InvoiceSerialNum: serialNo}, nil
+	})
+
+	p.handle("nuonuo.OpeMplatform.queryInvoiceResult", func(body []byte) (any, error) {
+		var req QueryInvoiceRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		if len(req.OrderNos) > 0 {
+			return []*InvoiceResultItem{{
+				SerialNo: p.orders[req.OrderNos[0]], OrderNo: req.OrderNos[0], Status: InvoiceStatusIssuing,
+			}}, nil
+		}
+
+		item := &InvoiceResultItem{SerialNo: req.SerialNos[0], Status: InvoiceStatusCompleted}
+		if item.SerialNo != "RED1" && p.failures > 0 {
+			p.failures--
+			item.Status = p.failStatus
+			item.FailCause = "购方信息有误"
+		}
+
+		return []*InvoiceResultItem{item}, nil
+	})
+
+	return p
+}
+
+func newTestReissueRequest(store ReissueStore) *ReissueRequest {
+	return &ReissueRequest{
+		Blue: &InvoiceResultItem{
+			SerialNo:    "BLUE1",
+			OrderNo:     "ORDER1",
+			InvoiceKind: InvoiceLineElectronicNormal,
+			InvoiceCode: "033002000111",
+			InvoiceNo:   "12345678",
+		},
+		Order: &InvoiceOrder{
+			BuyerName:   "购方名称",
+			SalerTaxNum: "339901999999199",
+			InvoiceType: "1",
+			InvoiceLine: InvoiceLineElectronicNormal,
+			InvoiceDetail: []*GoodsItem{
+				{GoodsName: "服务费", TaxRate: "0.06", TaxIncludedAmount: "106", WithTaxFlag: "1"},
+			},
+		},
+		Store:       store,
+		PollOptions: &WaitOptions{Interval: time.Millisecond},
+	}
+}
+
+// crashingStore 模拟保存指定步骤时进程崩溃
+type crashingStore struct {
+	ReissueStore
+	crashAt ReissueStep
+}
+
+func (s *crashingStore) Save(ctx context.Context, state *ReissueState) error {
+	if state.Step == s.crashAt {
+		s.crashAt = "-"
+		return errors.New("crashed")
+	}
+
+	return s.ReissueStore.Save(ctx, state)
+}
+
+func TestClient_ReissueInvoice_CrashAfterOpenInvoice(t *testing.T) {
+	p := newReissuePlatform(t, 0)
+	store := &crashingStore{ReissueStore: NewMemoryReissueStore(), crashAt: ReissueStepPending}
+	req := newTestReissueRequest(store)
+
+	// OpenInvoice 成功后保存状态前中断
+	_, err := p.client().ReissueInvoice(context.Background(), req)
+	require.Error(t, err)
+
+	state, err := store.Load(context.Background(), "BLUE1")
+	require.NoError(t, err)
+	assert.Equal(t, ReissueStepIssuing, state.Step)
+	assert.Empty(t, state.NewSerialNo)
+
+	// 重新执行时订单号重复，按订单号找回流水号，不重复开票
+	item, err := p.client().ReissueInvoice(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "NEW-ORDER1C1", item.SerialNo)
+	assert.Equal(t, []string{"ORDER1C1"}, p.orderNos)
+	assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.fastInvoiceRed"))
+}
+
+func TestClient_ReissueInvoice_RetryAfterFailure(t *testing.T) {
+	p := newReissuePlatform(t, 1)
+	store := NewMemoryReissueStore()
+
+	item, err := p.client().ReissueInvoice(context.Background(), newTestReissueRequest(store))
+	require.NoError(t, err)
+
+	// 第一次开具失败后以新的订单号重新开具
+	assert.Equal(t, []string{"ORDER1C1", "ORDER1C2"}, p.orderNos)
+	assert.Equal(t, "NEW-ORDER1C2", item.SerialNo)
+
+	state, err := store.Load(context.Background(), "BLUE1")
+	require.NoError(t, err)
+	assert.Equal(t, ReissueStepCompleted, state.Step)
+	assert.Equal(t, 2, state.Attempts)
+	assert.Empty(t, state.Error)
+}
+
+func TestClient_ReissueInvoice_MaxAttempts(t *testing.T) {
+	p := newReissuePlatform(t, 10)
+	req := newTestReissueRequest(NewMemoryReissueStore())
+	req.MaxAttempts = 2
+
+	_, err := p.client().ReissueInvoice(context.Background(), req)
+
+	var reissueErr *ReissueFailedError
+	require.ErrorAs(t, err, &reissueErr)
+	assert.Equal(t, ReissueStepFailed, reissueErr.State.Step)
+	assert.Equal(t, 2, reissueErr.State.Attempts)
+	assert.Contains(t, reissueErr.State.Error, "购方信息有误")
+	assert.Equal(t, RedReversalStepCompleted, reissueErr.State.Reversal.Step)
+	assert.Equal(t, []string{"ORDER1C1", "ORDER1C2"}, p.orderNos)
+
+	// 失败状态再次执行不再开票
+	_, err = p.client().ReissueInvoice(context.Background(), req)
+	assert.ErrorAs(t, err, &reissueErr)
+	assert.Len(t, p.orderNos, 2)
+}
+
+func TestClient_ReissueInvoice_SignFailed(t *testing.T) {
+	p := newReissuePlatform(t, 1)
+	p.failStatus = InvoiceStatusSignFailed
+
+	item, err := p.client().ReissueInvoice(context.Background(), newTestReissueRequest(NewMemoryReissueStore()))
+	require.NoError(t, err)
+
+	// 签章失败的新票已开具，不重新开具
+	assert.Equal(t, InvoiceStatusSignFailed, item.Status)
+	assert.Equal(t, []string{"ORDER1C1"}, p.orderNos)
+}
+
+func TestClient_ReissueInvoice_Invalidated(t *testing.T) {
+	p := newReissuePlatform(t, 1)
+	p.failStatus = InvoiceStatusInvalidated
+
+	_, err := p.client().ReissueInvoice(context.Background(), newTestReissueRequest(NewMemoryReissueStore()))
+
+	// 新票已作废时不视为完成，也不自动重开
+	var reissueErr *ReissueFailedError
+	require.ErrorAs(t, err, &reissueErr)
+	assert.Equal(t, ReissueStepFailed, reissueErr.State.Step)
+	assert.Equal(t, InvoiceStatusInvalidated, reissueErr.State.NewInvoice.Status)
+	assert.Equal(t, []string{"ORDER1C1"}, p.orderNos)
+}
@@ -0,0 +1,87 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// ResponseMeta 接口响应的原始报文，可用于读取 SDK 尚未定义的字段或留存审计
+type ResponseMeta struct {
+	Method   string          // 接口方法名
+	Code     string          // 返回码，E0000 为成功
+	Describe string          // 返回描述
+	Result   json.RawMessage // 原始 result 内容
+	List     json.RawMessage // 原始 list 内容
+	Body     []byte          // 完整响应报文
+}
+
+// Decode 将原始 result 解析到 v，用于读取响应结构体中未定义的字段
+func (m *ResponseMeta) Decode(v any) error {
+	return json.Unmarshal(m.Result, v)
+}
+
+type responseRecorderKey struct{}
+
+// ResponseRecorder 收集接口调用的原始响应，可并发使用。
+//
+// 一个 ctx 可能对应多次接口调用：OpenInvoice 启用额度校验时会先查询额度，
+// WaitForInvoice 每次轮询查询一次，QueryInvoicesBatch、RedeliverInvoices 等并发调用多次。
+// 所有响应按收到的先后顺序保存，Last 返回最后收到的响应。
+//
+//	rec := &ResponseRecorder{}
+//	resp, err := c.QueryInvoice(WithResponseRecorder(ctx, rec), req)
+//	var raw []map[string]any
+//	err = rec.Last().Decode(&raw)
+type ResponseRecorder struct {
+	mu        sync.Mutex
+	responses []*ResponseMeta
+}
+
+// Responses 返回已收到的全部响应
+func (r *ResponseRecorder) Responses() []*ResponseMeta {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*ResponseMeta(nil), r.responses...)
+}
+
+// Last 返回最后收到的响应，未收到响应时返回 nil
+func (r *ResponseRecorder) Last() *ResponseMeta {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.responses) == 0 {
+		return nil
+	}
+
+	return r.responses[len(r.responses)-1]
+}
+
+func (r *ResponseRecorder) add(meta *ResponseMeta) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.responses = append(r.responses, meta)
+}
+
+// WithResponseRecorder 返回携带 rec 的 ctx，使用该 ctx 的接口调用收到的响应都记录到 rec
+func WithResponseRecorder(ctx context.Context, rec *ResponseRecorder) context.Context {
+	return context.WithValue(ctx, responseRecorderKey{}, rec)
+}
+
+// OnResponse 注册响应处理函数，每次接口调用收到平台响应后调用，包括失败的响应。
+// 批量接口会并发调用 fn，fn 需支持并发调用。
+func (c *Client) OnResponse(fn func(ctx context.Context, meta *ResponseMeta)) {
+	c.onResponse = fn
+}
+
+func (c *Client) recordResponse(ctx context.Context, meta *ResponseMeta) {
+	if rec, ok := ctx.Value(responseRecorderKey{}).(*ResponseRecorder); ok && rec != nil {
+		rec.add(meta)
+	}
+
+	if c.onResponse != nil {
+		c.onResponse(ctx, meta)
+	}
+}
@@ -0,0 +1,73 @@
+package nuonuo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithResponseRecorder(t *testing.T) {
+	body := `{"code":"E0000","describe":"获取成功","result":[{"serialNo":"S001","newField":"x"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("method") == "nuonuo.OpeMplatform.invoiceCancellation" {
+			body = `{"code":"E9999","describe":"作废失败"}`
+		}
+
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	c := New(server.URL, "key", "secret", "", NewPermanentToken("token"))
+
+	recorded := []*ResponseMeta{}
+	c.OnResponse(func(ctx context.Context, meta *ResponseMeta) {
+		recorded = append(recorded, meta)
+	})
+
+	rec := &ResponseRecorder{}
+	items, err := c.QueryInvoice(WithResponseRecorder(context.Background(), rec), &QueryInvoiceRequest{})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+
+	meta := rec.Last()
+	require.NotNil(t, meta)
+
+	assert.Equal(t, "nuonuo.OpeMplatform.queryInvoiceResult", meta.Method)
+	assert.Equal(t, "E0000", meta.Code)
+	assert.Equal(t, body, string(meta.Body))
+
+	var raw []map[string]any
+	require.NoError(t, meta.Decode(&raw))
+	assert.Equal(t, "x", raw[0]["newField"])
+
+	_, err = c.InvalidateInvoice(context.Background(), &InvalidateInvoiceRequest{})
+	assert.Error(t, err)
+
+	require.Len(t, recorded, 2)
+	assert.Equal(t, "E9999", recorded[1].Code)
+	assert.Equal(t, "作废失败", recorded[1].Describe)
+}
+
+func TestWithResponseRecorder_Batch(t *testing.T) {
+	p := newFakePlatform(t)
+	p.handle("nuonuo.OpeMplatform.queryInvoiceResult", func(body []byte) (any, error) {
+		return []*InvoiceResultItem{}, nil
+	})
+
+	serialNos := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		serialNos = append(serialNos, strconv.Itoa(i))
+	}
+
+	rec := &ResponseRecorder{}
+	_, err := p.client().QueryInvoicesBatch(WithResponseRecorder(context.Background(), rec), &QueryInvoicesBatchRequest{
+		SerialNos: serialNos,
+	})
+	require.NoError(t, err)
+	assert.Len(t, rec.Responses(), 4)
+}
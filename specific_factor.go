@@ -0,0 +1,248 @@
+package nuonuo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 特定要素（仅数电发票）
+const (
+	SpecificFactorNone               = "0" // 普通发票
+	SpecificFactorRefinedOil         = "1" // 成品油
+	SpecificFactorConstruction       = "3" // 建筑服务
+	SpecificFactorGoodsTransport     = "4" // 货物运输服务
+	SpecificFactorRealEstateSale     = "5" // 不动产销售
+	SpecificFactorRealEstateLease    = "6" // 不动产经营租赁
+	SpecificFactorPassengerTransport = "9" // 旅客运输服务
+)
+
+var ErrSpecificFactor = errors.New("invalid specific factor")
+
+type (
+	// ConstructionInfo 建筑服务特定要素
+	ConstructionInfo struct {
+		ItemAddress     string `json:"itemAddress"`             // 建筑服务发生地（省市区）
+		DetailedAddress string `json:"detailedAddress"`         // 详细地址
+		ItemName        string `json:"itemName"`                // 建筑项目名称
+		CrossCityFlag   string `json:"crossCityFlag"`           // 跨地（市）标志：0 否 1 是
+		LandVatItemNo   string `json:"landVatItemNo,omitempty"` // 土地增值税项目编号
+	}
+
+	// GoodsTransportItem 货物运输服务特定要素
+	GoodsTransportItem struct {
+		TransportTool    string `json:"transportTool"`    // 运输工具种类
+		TransportToolNum string `json:"transportToolNum"` // 运输工具牌号
+		Origin           string `json:"origin"`           // 起运地
+		Destination      string `json:"destination"`      // 到达地
+		GoodsName        string `json:"goodsName"`        // 运输货物名称
+	}
+
+	// RealEstateSaleInfo 不动产销售特定要素
+	RealEstateSaleInfo struct {
+		RealPropertyAddress     string `json:"realPropertyAddress"`               // 不动产地址（省市区）
+		DetailAddress           string `json:"detailAddress"`                     // 详细地址
+		CrossCityFlag           string `json:"crossCityFlag"`                     // 跨地（市）标志：0 否 1 是
+		AreaUnit                string `json:"areaUnit"`                          // 面积单位
+		RealPropertyCertificate string `json:"realPropertyCertificate,omitempty"` // 不动产权证号
+		ContractNo              string `json:"contractNo,omitempty"`              // 网签合同备案编号
+		LandVatItemNo           string `json:"landVatItemNo,omitempty"`           // 土地增值税项目编号
+	}
+
+	// RealEstateLeaseInfo 不动产经营租赁特定要素
+	RealEstateLeaseInfo struct {
+		RealPropertyAddress     string `json:"realPropertyAddress"`               // 不动产地址（省市区）
+		DetailAddress           string `json:"detailAddress"`                     // 详细地址
+		RentStartDate           string `json:"rentStartDate"`                     // 租赁期起（yyyy-MM-dd）
+		RentEndDate             string `json:"rentEndDate"`                       // 租赁期止（yyyy-MM-dd）
+		CrossCityFlag           string `json:"crossCityFlag"`                     // 跨地（市）标志：0 否 1 是
+		AreaUnit                string `json:"areaUnit"`                          // 面积单位
+		RealPropertyCertificate string `json:"realPropertyCertificate,omitempty"` // 不动产权证号
+	}
+)
+
+type requiredField struct {
+	name  string
+	value string
+}
+
+func requireFields(kind string, fields ...requiredField) error {
+	missing := []string{}
+	for _, f := range fields {
+		if strings.TrimSpace(f.value) == "" {
+			missing = append(missing, f.name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s requires %s", ErrSpecificFactor, kind, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+func (info *ConstructionInfo) validate() error {
+	return requireFields("construction",
+		requiredField{"itemAddress", info.ItemAddress},
+		requiredField{"detailedAddress", info.DetailedAddress},
+		requiredField{"itemName", info.ItemName},
+		requiredField{"crossCityFlag", info.CrossCityFlag},
+	)
+}
+
+func (item *GoodsTransportItem) validate() error {
+	return requireFields("goods transport",
+		requiredField{"transportTool", item.TransportTool},
+		requiredField{"transportToolNum", item.TransportToolNum},
+		requiredField{"origin", item.Origin},
+		requiredField{"destination", item.Destination},
+		requiredField{"goodsName", item.GoodsName},
+	)
+}
+
+func (info *RealEstateSaleInfo) validate() error {
+	return requireFields("real estate sale",
+		requiredField{"realPropertyAddress", info.RealPropertyAddress},
+		requiredField{"detailAddress", info.DetailAddress},
+		requiredField{"crossCityFlag", info.CrossCityFlag},
+		requiredField{"areaUnit", info.AreaUnit},
+	)
+}
+
+func (info *RealEstateLeaseInfo) validate() error {
+	return requireFields("real estate lease",
+		requiredField{"realPropertyAddress", info.RealPropertyAddress},
+		requiredField{"detailAddress", info.DetailAddress},
+		requiredField{"rentStartDate", info.RentStartDate},
+		requiredField{"rentEndDate", info.RentEndDate},
+		requiredField{"crossCityFlag", info.CrossCityFlag},
+		requiredField{"areaUnit", info.AreaUnit},
+	)
+}
+
+func (item *TravellerTransportItem) validate() error {
+	return requireFields("passenger transport",
+		requiredField{"travelDate", item.TravelDate},
+		requiredField{"travelPlace", item.TravelPlace},
+		requiredField{"arrivePlace", item.ArrivePlace},
+		requiredField{"vehicleType", item.VehicleType},
+	)
+}
+
+// SetConstruction 设置建筑服务特定要素
+func (o *InvoiceOrder) SetConstruction(info *ConstructionInfo) error {
+	if err := info.validate(); err != nil {
+		return err
+	}
+
+	o.SpecificFactor = SpecificFactorConstruction
+	o.InvoiceBuildingInfo = info
+
+	return nil
+}
+
+// SetGoodsTransports 设置货物运输服务特定要素
+func (o *InvoiceOrder) SetGoodsTransports(items ...*GoodsTransportItem) error {
+	if len(items) == 0 {
+		return fmt.Errorf("%w: goods transport requires at least one item", ErrSpecificFactor)
+	}
+
+	for _, item := range items {
+		if err := item.validate(); err != nil {
+			return err
+		}
+	}
+
+	o.SpecificFactor = SpecificFactorGoodsTransport
+	o.InvoiceGoodsTransports = items
+
+	return nil
+}
+
+// SetRealEstateSale 设置不动产销售特定要素
+func (o *InvoiceOrder) SetRealEstateSale(info *RealEstateSaleInfo) error {
+	if err := info.validate(); err != nil {
+		return err
+	}
+
+	o.SpecificFactor = SpecificFactorRealEstateSale
+	o.RealPropertySellInfo = info
+
+	return nil
+}
+
+// SetRealEstateLease 设置不动产经营租赁特定要素
+func (o *InvoiceOrder) SetRealEstateLease(info *RealEstateLeaseInfo) error {
+	if err := info.validate(); err != nil {
+		return err
+	}
+
+	o.SpecificFactor = SpecificFactorRealEstateLease
+	o.RealPropertyRentInfo = info
+
+	return nil
+}
+
+// SetPassengerTransports 设置旅客运输服务特定要素
+func (o *InvoiceOrder) SetPassengerTransports(items ...*TravellerTransportItem) error {
+	if len(items) == 0 {
+		return fmt.Errorf("%w: passenger transport requires at least one item", ErrSpecificFactor)
+	}
+
+	for _, item := range items {
+		if err := item.validate(); err != nil {
+			return err
+		}
+	}
+
+	o.SpecificFactor = SpecificFactorPassengerTransport
+	o.InvoiceTravellerTransportInfoList = items
+
+	return nil
+}
+
+// checkSpecificFactor 校验特定要素与对应的要素信息一致
+func checkSpecificFactor(o *InvoiceOrder) error {
+	switch o.SpecificFactor {
+	case SpecificFactorConstruction:
+		if o.InvoiceBuildingInfo == nil {
+			return fmt.Errorf("%w: invoiceBuildingInfo required", ErrSpecificFactor)
+		}
+
+		return o.InvoiceBuildingInfo.validate()
+	case SpecificFactorGoodsTransport:
+		if len(o.InvoiceGoodsTransports) == 0 {
+			return fmt.Errorf("%w: invoiceGoodsTransports required", ErrSpecificFactor)
+		}
+
+		for _, item := range o.InvoiceGoodsTransports {
+			if err := item.validate(); err != nil {
+				return err
+			}
+		}
+	case SpecificFactorRealEstateSale:
+		if o.RealPropertySellInfo == nil {
+			return fmt.Errorf("%w: realPropertySellInfo required", ErrSpecificFactor)
+		}
+
+		return o.RealPropertySellInfo.validate()
+	case SpecificFactorRealEstateLease:
+		if o.RealPropertyRentInfo == nil {
+			return fmt.Errorf("%w: realPropertyRentInfo required", ErrSpecificFactor)
+		}
+
+		return o.RealPropertyRentInfo.validate()
+	case SpecificFactorPassengerTransport:
+		if len(o.InvoiceTravellerTransportInfoList) == 0 {
+			return fmt.Errorf("%w: invoiceTravellerTransportInfoList required", ErrSpecificFactor)
+		}
+
+		for _, item := range o.InvoiceTravellerTransportInfoList {
+			if err := item.validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
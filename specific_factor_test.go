@@ -0,0 +1,34 @@
+package nuonuo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoiceOrder_SetConstruction(t *testing.T) {
+	order := &InvoiceOrder{}
+
+	err := order.SetConstruction(&ConstructionInfo{ItemAddress: "浙江省杭州市西湖区"})
+	require.ErrorIs(t, err, ErrSpecificFactor)
+	assert.Contains(t, err.Error(), "detailedAddress, itemName, crossCityFlag")
+	assert.Empty(t, order.SpecificFactor)
+
+	require.NoError(t, order.SetConstruction(&ConstructionInfo{
+		ItemAddress:     "浙江省杭州市西湖区",
+		DetailedAddress: "文三路1号",
+		ItemName:        "办公楼",
+		CrossCityFlag:   "0",
+	}))
+	assert.Equal(t, SpecificFactorConstruction, order.SpecificFactor)
+	assert.NoError(t, order.Validate())
+
+	data, err := json.Marshal(order)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"specificFactor":"3","invoiceBuildingInfo":{"itemAddress"`)
+
+	order.SpecificFactor = SpecificFactorGoodsTransport
+	assert.ErrorIs(t, order.Validate(), ErrSpecificFactor)
+}
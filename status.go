@@ -0,0 +1,172 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// InvoiceStatus 发票开具状态
+type InvoiceStatus string
+
+const (
+	InvoiceStatusCompleted    InvoiceStatus = "2"  // 开票完成
+	InvoiceStatusIssuing      InvoiceStatus = "20" // 开票中
+	InvoiceStatusSigning      InvoiceStatus = "21" // 开票成功签章中
+	InvoiceStatusFailed       InvoiceStatus = "22" // 开票失败
+	InvoiceStatusSignFailed   InvoiceStatus = "24" // 开票成功签章失败
+	InvoiceStatusInvalidated  InvoiceStatus = "3"  // 发票已作废
+	InvoiceStatusInvalidating InvoiceStatus = "31" // 发票作废中
+)
+
+var ErrInvoiceInvalidated = errors.New("invoice invalidated")
+
+// 是否最终状态
+func (s InvoiceStatus) IsTerminal() bool {
+	switch s {
+	case InvoiceStatusCompleted, InvoiceStatusFailed, InvoiceStatusSignFailed, InvoiceStatusInvalidated:
+		return true
+	default:
+		return false
+	}
+}
+
+// 是否开票成功
+func (s InvoiceStatus) IsSuccess() bool {
+	return s == InvoiceStatusCompleted
+}
+
+// 是否已开具，签章失败的发票已开具，只是未签章
+func (s InvoiceStatus) IsIssued() bool {
+	return s == InvoiceStatusCompleted || s == InvoiceStatusSignFailed
+}
+
+// 是否开票失败，发票未开具
+func (s InvoiceStatus) IsFailed() bool {
+	return s == InvoiceStatusFailed
+}
+
+// 是否开票成功但签章失败
+func (s InvoiceStatus) IsSignFailed() bool {
+	return s == InvoiceStatusSignFailed
+}
+
+// InvoiceFailedError 发票开具失败
+type InvoiceFailedError struct {
+	Item *InvoiceResultItem
+}
+
+func (e *InvoiceFailedError) Error() string {
+	return fmt.Sprintf("invoice %s %s: %s", e.Item.SerialNo, e.Item.Status, e.Item.FailCause)
+}
+
+// 失败原因
+func (e *InvoiceFailedError) FailCause() string {
+	return e.Item.FailCause
+}
+
+// WaitOptions 轮询开票结果的参数
+type WaitOptions struct {
+	Interval    time.Duration // 首次轮询间隔，默认1秒
+	MaxInterval time.Duration // 最大轮询间隔，默认30秒
+	Multiplier  float64       // 间隔增长倍数，默认2
+}
+
+func (o *WaitOptions) withDefaults() WaitOptions {
+	opts := WaitOptions{
+		Interval:    time.Second,
+		MaxInterval: 30 * time.Second,
+		Multiplier:  2,
+	}
+
+	if o == nil {
+		return opts
+	}
+
+	if o.Interval > 0 {
+		opts.Interval = o.Interval
+	}
+
+	if o.MaxInterval > 0 {
+		opts.MaxInterval = o.MaxInterval
+	}
+
+	if o.Multiplier >= 1 {
+		opts.Multiplier = o.Multiplier
+	}
+
+	return opts
+}
+
+func (o *WaitOptions) next(interval time.Duration) time.Duration {
+	interval = time.Duration(float64(interval) * o.Multiplier)
+	if interval > o.MaxInterval {
+		interval = o.MaxInterval
+	}
+
+	return interval
+}
+
+// WaitForInvoice 轮询发票开具结果，直到发票处于最终状态或 ctx 结束。
+// 开票失败时返回 *InvoiceFailedError，发票已作废时返回 ErrInvoiceInvalidated。
+// 签章失败的发票已开具，与开票完成一样返回 nil 错误，可通过 Status.IsSignFailed 判断。
+// 平台返回的 *Error 直接返回，网络错误等其他错误按相同的间隔继续轮询，
+// ctx 结束时返回的错误包含最后一次查询错误。
+func (c *Client) WaitForInvoice(
+	ctx context.Context, serialNo string, opts *WaitOptions,
+) (*InvoiceResultItem, error) {
+	o := opts.withDefaults()
+	interval := o.Interval
+
+	var lastErr error
+
+	for {
+		items, err := c.QueryInvoice(ctx, &QueryInvoiceRequest{SerialNos: []string{serialNo}})
+
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			return nil, err
+		}
+
+		lastErr = err
+
+		for _, item := range items {
+			if item.SerialNo != serialNo || !item.Status.IsTerminal() {
+				continue
+			}
+
+			if item.Status.IsFailed() {
+				return item, &InvoiceFailedError{Item: item}
+			}
+
+			if item.Status == InvoiceStatusInvalidated {
+				return item, fmt.Errorf("%w: %s", ErrInvoiceInvalidated, serialNo)
+			}
+
+			return item, nil
+		}
+
+		if err := sleepContext(ctx, interval); err != nil {
+			if lastErr != nil {
+				return nil, fmt.Errorf("wait invoice %s: %w: last error: %w", serialNo, err, lastErr)
+			}
+
+			return nil, fmt.Errorf("wait invoice %s: %w", serialNo, err)
+		}
+
+		interval = o.next(interval)
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
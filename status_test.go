@@ -0,0 +1,129 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvoiceStatus(t *testing.T) {
+	assert.True(t, InvoiceStatusCompleted.IsTerminal())
+	assert.True(t, InvoiceStatusCompleted.IsSuccess())
+	assert.False(t, InvoiceStatusIssuing.IsTerminal())
+	assert.False(t, InvoiceStatusSigning.IsTerminal())
+	assert.True(t, InvoiceStatusFailed.IsFailed())
+	assert.False(t, InvoiceStatusFailed.IsIssued())
+	assert.True(t, InvoiceStatusSignFailed.IsTerminal())
+	assert.True(t, InvoiceStatusSignFailed.IsIssued())
+	assert.True(t, InvoiceStatusSignFailed.IsSignFailed())
+	assert.False(t, InvoiceStatusSignFailed.IsFailed())
+	assert.False(t, InvoiceStatusInvalidated.IsFailed())
+	assert.False(t, InvoiceStatusInvalidated.IsIssued())
+	assert.False(t, InvoiceStatusInvalidating.IsTerminal())
+}
+
+func TestWaitOptions(t *testing.T) {
+	var opts *WaitOptions
+
+	o := opts.withDefaults()
+	assert.Equal(t, 2*o.Interval, o.next(o.Interval))
+	assert.Equal(t, o.MaxInterval, o.next(o.MaxInterval))
+}
+
+func newWaitPlatform(t *testing.T, results ...any) *fakePlatform {
+	p := newFakePlatform(t)
+
+	var mu sync.Mutex
+	p.handle("nuonuo.OpeMplatform.queryInvoiceResult", func(body []byte) (any, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		result := results[0]
+		if len(results) > 1 {
+			results = results[1:]
+		}
+
+		switch r := result.(type) {
+		case error:
+			return nil, r
+		case InvoiceStatus:
+			return []*InvoiceResultItem{{SerialNo: "S1", Status: r, FailCause: "失败原因"}}, nil
+		}
+
+		return nil, fmt.Errorf("unexpected result %v", result)
+	})
+
+	return p
+}
+
+func TestClient_WaitForInvoice(t *testing.T) {
+	opts := &WaitOptions{Interval: time.Millisecond}
+
+	t.Run("transient errors", func(t *testing.T) {
+		unavailable := errors.New("unavailable")
+		p := newWaitPlatform(t, unavailable, unavailable, InvoiceStatusIssuing, InvoiceStatusCompleted)
+
+		// 网络错误等非平台错误继续轮询
+		item, err := p.client().WaitForInvoice(context.Background(), "S1", opts)
+		require.NoError(t, err)
+		assert.Equal(t, InvoiceStatusCompleted, item.Status)
+		assert.Equal(t, 4, p.count("nuonuo.OpeMplatform.queryInvoiceResult"))
+	})
+
+	t.Run("platform error", func(t *testing.T) {
+		p := newWaitPlatform(t, &Error{Code: "E9999", Msg: "流水号不存在"}, InvoiceStatusCompleted)
+
+		_, err := p.client().WaitForInvoice(context.Background(), "S1", opts)
+
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, "E9999", apiErr.Code)
+		assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.queryInvoiceResult"))
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		p := newWaitPlatform(t, InvoiceStatusFailed)
+
+		item, err := p.client().WaitForInvoice(context.Background(), "S1", opts)
+
+		var failedErr *InvoiceFailedError
+		require.ErrorAs(t, err, &failedErr)
+		assert.Equal(t, "失败原因", failedErr.FailCause())
+		assert.Equal(t, item, failedErr.Item)
+	})
+
+	t.Run("sign failed", func(t *testing.T) {
+		p := newWaitPlatform(t, InvoiceStatusSignFailed)
+
+		// 签章失败的发票已开具，不视为开票失败
+		item, err := p.client().WaitForInvoice(context.Background(), "S1", opts)
+		require.NoError(t, err)
+		assert.True(t, item.Status.IsSignFailed())
+	})
+
+	t.Run("invalidated", func(t *testing.T) {
+		p := newWaitPlatform(t, InvoiceStatusInvalidated)
+
+		item, err := p.client().WaitForInvoice(context.Background(), "S1", opts)
+		assert.ErrorIs(t, err, ErrInvoiceInvalidated)
+		assert.Equal(t, InvoiceStatusInvalidated, item.Status)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		p := newWaitPlatform(t, errors.New("unavailable"))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		// 超时返回的错误包含最后一次查询错误
+		_, err := p.client().WaitForInvoice(ctx, "S1", opts)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Contains(t, err.Error(), "last error")
+	})
+}
@@ -0,0 +1,43 @@
+package nuonuo
+
+import (
+	"context"
+	"sort"
+)
+
+// InvoiceStock 按发票种类汇总的剩余份数
+type InvoiceStock map[string]int
+
+// SummarizeInvoiceStock 按发票种类汇总库存
+func SummarizeInvoiceStock(items []*InvoiceStockItem) InvoiceStock {
+	stock := InvoiceStock{}
+	for _, item := range items {
+		stock[item.InvoiceLine] += item.RemainNum
+	}
+
+	return stock
+}
+
+// Low 返回剩余份数低于 threshold 的发票种类
+func (s InvoiceStock) Low(threshold int) []string {
+	lines := []string{}
+	for line, n := range s {
+		if n < threshold {
+			lines = append(lines, line)
+		}
+	}
+
+	sort.Strings(lines)
+
+	return lines
+}
+
+// InvoiceStockByLine 查询发票库存并按发票种类汇总
+func (c *Client) InvoiceStockByLine(ctx context.Context, req *QueryInvoiceStockRequest) (InvoiceStock, error) {
+	items, err := c.QueryInvoiceStock(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return SummarizeInvoiceStock(items), nil
+}
@@ -0,0 +1,94 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarizeInvoiceStock(t *testing.T) {
+	stock := SummarizeInvoiceStock([]*InvoiceStockItem{
+		{MachineCode: "M1", InvoiceLine: InvoiceLinePaperSpecial, RemainNum: 3},
+		{MachineCode: "M2", InvoiceLine: InvoiceLinePaperSpecial, RemainNum: 4},
+		{MachineCode: "M1", InvoiceLine: InvoiceLinePaperNormal, RemainNum: 20},
+		{MachineCode: "M1", InvoiceLine: InvoiceLineRollNormal, RemainNum: 0},
+	})
+
+	assert.Equal(t, InvoiceStock{
+		InvoiceLinePaperSpecial: 7,
+		InvoiceLinePaperNormal:  20,
+		InvoiceLineRollNormal:   0,
+	}, stock)
+
+	assert.Empty(t, SummarizeInvoiceStock(nil))
+}
+
+func TestInvoiceStock_Low(t *testing.T) {
+	stock := InvoiceStock{
+		InvoiceLinePaperSpecial: 7,
+		InvoiceLinePaperNormal:  20,
+		InvoiceLineRollNormal:   0,
+	}
+
+	// 按发票种类排序，等于阈值不视为不足
+	assert.Equal(t, []string{InvoiceLineRollNormal, InvoiceLinePaperSpecial}, stock.Low(10))
+	assert.Equal(t, []string{InvoiceLineRollNormal}, stock.Low(7))
+	assert.Empty(t, stock.Low(0))
+	assert.Empty(t, InvoiceStock{}.Low(10))
+}
+
+func TestClient_InvoiceStockByLine(t *testing.T) {
+	p := newFakePlatform(t)
+	p.handle("nuonuo.OpeMplatform.getInvoiceRepertoryInfo", func(body []byte) (any, error) {
+		return []*InvoiceStockItem{
+			{MachineCode: "M1", InvoiceLine: InvoiceLinePaperSpecial, RemainNum: 3},
+			{MachineCode: "M2", InvoiceLine: InvoiceLinePaperSpecial, RemainNum: 4},
+		}, nil
+	})
+
+	stock, err := p.client().InvoiceStockByLine(context.Background(), &QueryInvoiceStockRequest{TaxNum: "339901999999199"})
+	require.NoError(t, err)
+	assert.Equal(t, InvoiceStock{InvoiceLinePaperSpecial: 7}, stock)
+}
+
+func TestClient_PrintInvoice(t *testing.T) {
+	p := newFakePlatform(t)
+
+	var req map[string]string
+	p.handle("nuonuo.OpeMplatform.printInvoice", func(body []byte) (any, error) {
+		return nil, json.Unmarshal(body, &req)
+	})
+
+	err := p.client().PrintInvoice(context.Background(), &PrintInvoiceRequest{
+		TaxNum: "339901999999199", InvoiceID: "S1", PrintType: "1", MachineCode: "M1", OffsetX: "2",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"taxnum": "339901999999199", "invoiceId": "S1", "printType": "1", "machineCode": "M1", "offsetX": "2",
+	}, req)
+	assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.printInvoice"))
+}
+
+func TestClient_QueryTerminalList(t *testing.T) {
+	p := newFakePlatform(t)
+
+	var req map[string]string
+	p.handle("nuonuo.OpeMplatform.queryTerminalList", func(body []byte) (any, error) {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		return []*TerminalItem{{TerminalNumber: "T1", MachineCode: "M1", OnlineStatus: "1"}}, nil
+	})
+
+	terminals, err := p.client().QueryTerminalList(context.Background(), &QueryTerminalListRequest{
+		TaxNum: "339901999999199", ExtensionNumber: "0",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []*TerminalItem{{TerminalNumber: "T1", MachineCode: "M1", OnlineStatus: "1"}}, terminals)
+	assert.Equal(t, map[string]string{"taxnum": "339901999999199", "extensionNumber": "0"}, req)
+	assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.queryTerminalList"))
+}
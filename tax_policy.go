@@ -0,0 +1,160 @@
+package nuonuo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TaxPolicy 明细行适用的税收政策
+type TaxPolicy int
+
+const (
+	TaxPolicyNone               TaxPolicy = iota // 正常征税
+	TaxPolicyExempt                              // 免税
+	TaxPolicyNonTaxable                          // 不征税
+	TaxPolicyExportZeroRate                      // 出口零税率
+	TaxPolicyZeroRate                            // 普通零税率
+	TaxPolicySimplified3                         // 简易征收（3%）
+	TaxPolicySimplified5                         // 按5%简易征收
+	TaxPolicySimplified5Reduced                  // 按5%简易征收减按1.5%计征
+)
+
+// 零税率标识
+const (
+	ZeroRateFlagExport     = "0" // 出口零税
+	ZeroRateFlagExempt     = "1" // 免税
+	ZeroRateFlagNonTaxable = "2" // 不征税
+	ZeroRateFlagNormal     = "3" // 普通零税率
+)
+
+// 优惠政策名称
+const (
+	PolicyNameExempt             = "免税"
+	PolicyNameNonTaxable         = "不征税"
+	PolicyNameSimplified         = "简易征收"
+	PolicyNameSimplified5        = "按5%简易征收"
+	PolicyNameSimplified5Reduced = "按5%简易征收减按1.5%计征"
+)
+
+type taxPolicyRule struct {
+	taxRate            string
+	zeroRateFlag       string
+	favouredPolicyName string
+}
+
+var taxPolicyRules = map[TaxPolicy]taxPolicyRule{
+	TaxPolicyExempt:             {"0", ZeroRateFlagExempt, PolicyNameExempt},
+	TaxPolicyNonTaxable:         {"0", ZeroRateFlagNonTaxable, PolicyNameNonTaxable},
+	TaxPolicyExportZeroRate:     {"0", ZeroRateFlagExport, ""},
+	TaxPolicyZeroRate:           {"0", ZeroRateFlagNormal, ""},
+	TaxPolicySimplified3:        {"0.03", "", PolicyNameSimplified},
+	TaxPolicySimplified5:        {"0.05", "", PolicyNameSimplified5},
+	TaxPolicySimplified5Reduced: {"0.015", "", PolicyNameSimplified5Reduced},
+}
+
+// 优惠政策对应的税率，未列出的政策不限制税率
+var policyNameRates = map[string]string{
+	PolicyNameExempt:             "0",
+	PolicyNameNonTaxable:         "0",
+	PolicyNameSimplified5:        "0.05",
+	PolicyNameSimplified5Reduced: "0.015",
+}
+
+var ErrInvalidTaxPolicy = errors.New("invalid tax policy")
+
+// ApplyTaxPolicy 按税收政策设置明细行的税率、零税率标识与优惠政策。
+// 正常征税时清除零税率标识与优惠政策，税率保持不变。
+func ApplyTaxPolicy(item *GoodsItem, policy TaxPolicy) error {
+	if policy == TaxPolicyNone {
+		item.ZeroRateFlag = ""
+		item.FavouredPolicyFlag = "0"
+		item.FavouredPolicyName = ""
+
+		return nil
+	}
+
+	rule, ok := taxPolicyRules[policy]
+	if !ok {
+		return fmt.Errorf("%w: unknown policy %d", ErrInvalidTaxPolicy, policy)
+	}
+
+	item.TaxRate = rule.taxRate
+	item.ZeroRateFlag = rule.zeroRateFlag
+	item.FavouredPolicyName = rule.favouredPolicyName
+	item.FavouredPolicyFlag = "0"
+
+	if rule.favouredPolicyName != "" {
+		item.FavouredPolicyFlag = "1"
+	}
+
+	return nil
+}
+
+// CheckTaxPolicy 校验明细行税率、零税率标识与优惠政策的组合
+func CheckTaxPolicy(item *GoodsItem) error {
+	rate, err := parseDecimal(item.TaxRate)
+	if err != nil {
+		return fmt.Errorf("%w: taxRate %q", ErrInvalidTaxPolicy, item.TaxRate)
+	}
+
+	flagged := item.FavouredPolicyFlag == "1"
+
+	switch {
+	case flagged && item.FavouredPolicyName == "":
+		return fmt.Errorf("%w: favouredPolicyName required when favouredPolicyFlag is 1", ErrInvalidTaxPolicy)
+	case !flagged && item.FavouredPolicyName != "":
+		return fmt.Errorf(
+			"%w: favouredPolicyFlag must be 1 when favouredPolicyName is %s",
+			ErrInvalidTaxPolicy, item.FavouredPolicyName,
+		)
+	}
+
+	if want, ok := policyNameRates[item.FavouredPolicyName]; ok {
+		if r, _ := parseDecimal(want); r.Cmp(rate) != 0 {
+			return fmt.Errorf(
+				"%w: taxRate must be %s for %s, got %s",
+				ErrInvalidTaxPolicy, want, item.FavouredPolicyName, item.TaxRate,
+			)
+		}
+	}
+
+	if item.ZeroRateFlag == "" {
+		if item.TaxRate != "" && rate.Sign() == 0 {
+			return fmt.Errorf("%w: zeroRateFlag required when taxRate is 0", ErrInvalidTaxPolicy)
+		}
+
+		return nil
+	}
+
+	if rate.Sign() != 0 {
+		return fmt.Errorf("%w: taxRate must be 0 when zeroRateFlag is %s", ErrInvalidTaxPolicy, item.ZeroRateFlag)
+	}
+
+	var wantName string
+
+	switch item.ZeroRateFlag {
+	case ZeroRateFlagExempt:
+		wantName = PolicyNameExempt
+	case ZeroRateFlagNonTaxable:
+		wantName = PolicyNameNonTaxable
+	case ZeroRateFlagExport, ZeroRateFlagNormal:
+	default:
+		return fmt.Errorf("%w: unknown zeroRateFlag %q", ErrInvalidTaxPolicy, item.ZeroRateFlag)
+	}
+
+	if item.FavouredPolicyName != wantName {
+		if wantName == "" {
+			return fmt.Errorf(
+				"%w: zeroRateFlag %s does not allow favoured policy %s",
+				ErrInvalidTaxPolicy, item.ZeroRateFlag, item.FavouredPolicyName,
+			)
+		}
+
+		return fmt.Errorf(
+			"%w: zeroRateFlag %s requires favouredPolicyFlag 1 and favouredPolicyName %s",
+			ErrInvalidTaxPolicy, item.ZeroRateFlag, wantName,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package nuonuo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyTaxPolicy(t *testing.T) {
+	for _, policy := range []TaxPolicy{
+		TaxPolicyExempt, TaxPolicyNonTaxable, TaxPolicyExportZeroRate, TaxPolicyZeroRate,
+		TaxPolicySimplified3, TaxPolicySimplified5, TaxPolicySimplified5Reduced,
+	} {
+		item := &GoodsItem{TaxRate: "0.13"}
+		require.NoError(t, ApplyTaxPolicy(item, policy))
+		assert.NoError(t, CheckTaxPolicy(item), "policy %d", policy)
+	}
+
+	item := &GoodsItem{TaxRate: "0", ZeroRateFlag: "1", FavouredPolicyFlag: "1", FavouredPolicyName: "免税"}
+	require.NoError(t, ApplyTaxPolicy(item, TaxPolicyNone))
+	assert.Empty(t, item.ZeroRateFlag)
+	assert.Empty(t, item.FavouredPolicyName)
+}
+
+func TestCheckTaxPolicy(t *testing.T) {
+	cases := []*GoodsItem{
+		{TaxRate: "0"},
+		{TaxRate: "0.06", ZeroRateFlag: "1", FavouredPolicyFlag: "1", FavouredPolicyName: "免税"},
+		{TaxRate: "0", ZeroRateFlag: "1"},
+		{TaxRate: "0", ZeroRateFlag: "3", FavouredPolicyFlag: "1", FavouredPolicyName: "免税"},
+		{TaxRate: "0.03", FavouredPolicyFlag: "1"},
+		{TaxRate: "0.03", FavouredPolicyName: "简易征收"},
+		{TaxRate: "0.03", FavouredPolicyFlag: "1", FavouredPolicyName: "按5%简易征收"},
+	}
+
+	for i, item := range cases {
+		assert.ErrorIs(t, CheckTaxPolicy(item), ErrInvalidTaxPolicy, "case %d", i)
+	}
+
+	assert.NoError(t, CheckTaxPolicy(&GoodsItem{TaxRate: "0.13"}))
+	assert.NoError(t, CheckTaxPolicy(&GoodsItem{TaxRate: "0.03"}))
+
+	order := &InvoiceOrder{InvoiceDetail: []*GoodsItem{{TaxRate: "0.13"}, {TaxRate: "0"}}}
+
+	var lineErr *LineError
+	require.ErrorAs(t, order.Validate(), &lineErr)
+	assert.Equal(t, 1, lineErr.Index)
+}
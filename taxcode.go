@@ -0,0 +1,253 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+const taxCodeLen = 19
+
+var (
+	ErrInvalidTaxCode  = errors.New("invalid tax classification code")
+	ErrTaxCodeNotFound = errors.New("tax classification code not found")
+)
+
+// TaxCode 税收分类编码
+type TaxCode struct {
+	Code      string   `json:"code"`      // 19位税收分类编码
+	Name      string   `json:"name"`      // 商品和服务名称
+	ShortName string   `json:"shortName"` // 商品和服务分类简称
+	TaxRates  []string `json:"taxRates"`  // 适用税率，第一个为默认税率
+	Policies  []string `json:"policies"`  // 可享受的优惠政策
+}
+
+// DefaultTaxRate 默认税率
+func (t *TaxCode) DefaultTaxRate() string {
+	if len(t.TaxRates) == 0 {
+		return ""
+	}
+
+	return t.TaxRates[0]
+}
+
+// TaxCodeCatalogue 税收分类编码表
+type TaxCodeCatalogue struct {
+	Version string
+
+	// 编码表未收录时调用，可使用 Client.QueryTaxCode 在线查询
+	Fetcher func(ctx context.Context, code string) (*TaxCode, error)
+
+	codes  []*TaxCode
+	byCode map[string]*TaxCode
+}
+
+// LoadTaxCodeCatalogue 从 JSON 加载官方税收分类编码表，version 为编码表版本号，不能为空：
+//
+//	{"version": "...", "codes": [{"code": "...", "name": "...", "shortName": "...", "taxRates": ["0.13"], "policies": []}]}
+func LoadTaxCodeCatalogue(r io.Reader) (*TaxCodeCatalogue, error) {
+	var data struct {
+		Version string     `json:"version"`
+		Codes   []*TaxCode `json:"codes"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode tax codes: %w", err)
+	}
+
+	if data.Version == "" {
+		return nil, errors.New("tax codes without version")
+	}
+
+	c := &TaxCodeCatalogue{
+		Version: data.Version,
+		codes:   data.Codes,
+		byCode:  make(map[string]*TaxCode, len(data.Codes)),
+	}
+
+	for _, code := range data.Codes {
+		if !isTaxCode(code.Code) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidTaxCode, code.Code)
+		}
+
+		c.byCode[code.Code] = code
+	}
+
+	sort.Slice(c.codes, func(i, j int) bool {
+		return c.codes[i].Code < c.codes[j].Code
+	})
+
+	return c, nil
+}
+
+// Lookup 按编码查询，编码表与 Fetcher 均未收录时返回 ErrTaxCodeNotFound
+func (c *TaxCodeCatalogue) Lookup(ctx context.Context, code string) (*TaxCode, error) {
+	if t, ok := c.byCode[code]; ok {
+		return t, nil
+	}
+
+	if c.Fetcher != nil && isTaxCode(code) {
+		t, err := c.Fetcher(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+
+		if t != nil {
+			return t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrTaxCodeNotFound, code)
+}
+
+// Search 按编码前缀或名称关键字查询，最多返回 limit 条，limit 不大于0时不限制
+func (c *TaxCodeCatalogue) Search(keyword string, limit int) []*TaxCode {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return nil
+	}
+
+	result := []*TaxCode{}
+	for _, t := range c.codes {
+		if strings.HasPrefix(t.Code, keyword) ||
+			strings.Contains(t.Name, keyword) || strings.Contains(t.ShortName, keyword) {
+			result = append(result, t)
+			if limit > 0 && len(result) >= limit {
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// Validate 校验明细行的税收分类编码、税率与优惠政策名称是否匹配。
+// 编码表与 Fetcher 均未收录的编码返回的错误同时匹配 ErrInvalidTaxCode 与 ErrTaxCodeNotFound。
+func (c *TaxCodeCatalogue) Validate(ctx context.Context, item *GoodsItem) error {
+	if !isTaxCode(item.GoodsCode) {
+		return fmt.Errorf("%w: %q must be %d digits", ErrInvalidTaxCode, item.GoodsCode, taxCodeLen)
+	}
+
+	t, err := c.Lookup(ctx, item.GoodsCode)
+	if errors.Is(err, ErrTaxCodeNotFound) {
+		return fmt.Errorf("%w: %w", ErrInvalidTaxCode, err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if item.TaxRate != "" && !containsRate(t.TaxRates, item.TaxRate) {
+		// 免税、不征税等零税率明细不受编码税率限制
+		rate, err := parseDecimal(item.TaxRate)
+		if err != nil || rate.Sign() != 0 || item.ZeroRateFlag == "" {
+			return fmt.Errorf(
+				"%w: tax rate %s not applicable to %s(%s), expect one of %s",
+				ErrInvalidTaxCode, item.TaxRate, t.Code, t.Name, strings.Join(t.TaxRates, ","),
+			)
+		}
+	}
+
+	if item.FavouredPolicyName != "" && !containsString(t.Policies, item.FavouredPolicyName) {
+		return fmt.Errorf(
+			"%w: favoured policy %s not applicable to %s(%s)",
+			ErrInvalidTaxCode, item.FavouredPolicyName, t.Code, t.Name,
+		)
+	}
+
+	return nil
+}
+
+// TaxCodeFetcher 返回使用诺税通在线接口查询编码的 Fetcher
+func (c *Client) TaxCodeFetcher() func(ctx context.Context, code string) (*TaxCode, error) {
+	return func(ctx context.Context, code string) (*TaxCode, error) {
+		codes, err := c.QueryTaxCode(ctx, &QueryTaxCodeRequest{GoodsCode: code})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range codes {
+			if t.Code == code {
+				return t.TaxCode(), nil
+			}
+		}
+
+		return nil, nil
+	}
+}
+
+// TaxCode 转换为编码表格式，税率 13% 转换为 0.13
+func (r *TaxCodeResult) TaxCode() *TaxCode {
+	t := &TaxCode{Code: r.Code, Name: r.Name, ShortName: r.ShortName}
+
+	for _, rate := range splitList(r.TaxRates) {
+		if v, ok := strings.CutSuffix(rate, "%"); ok {
+			if d, err := parseDecimal(v); err == nil {
+				rate = formatQuantity(d.Quo(d, big.NewRat(100, 1)))
+			}
+		}
+
+		t.TaxRates = append(t.TaxRates, rate)
+	}
+
+	t.Policies = splitList(r.Policies)
+
+	return t
+}
+
+// splitList 拆分以顿号、逗号分隔的列表
+func splitList(s string) []string {
+	list := []string{}
+	for _, v := range strings.FieldsFunc(s, func(r rune) bool { return r == '、' || r == ',' || r == '，' }) {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+func isTaxCode(code string) bool {
+	if len(code) != taxCodeLen {
+		return false
+	}
+
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containsRate 比较税率数值，"0.1" 与 "0.10" 视为相同
+func containsRate(rates []string, rate string) bool {
+	r, err := parseDecimal(rate)
+	if err != nil {
+		return false
+	}
+
+	for _, s := range rates {
+		if v, err := parseDecimal(s); err == nil && v.Cmp(r) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
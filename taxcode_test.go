@@ -0,0 +1,90 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadTestTaxCodes(t *testing.T) *TaxCodeCatalogue {
+	f, err := os.Open("testdata/taxcodes.json")
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	c, err := LoadTaxCodeCatalogue(f)
+	require.NoError(t, err)
+
+	return c
+}
+
+func TestTaxCodeCatalogue(t *testing.T) {
+	ctx := context.Background()
+	c := loadTestTaxCodes(t)
+	assert.Equal(t, "test", c.Version)
+
+	found := c.Search("餐饮", 0)
+	require.Len(t, found, 1)
+	assert.Equal(t, "0.06", found[0].DefaultTaxRate())
+	assert.NotEmpty(t, c.Search("30502", 10))
+
+	require.NoError(t, c.Validate(ctx, &GoodsItem{GoodsCode: "3070401000000000000", TaxRate: "0.06"}))
+	require.NoError(t, c.Validate(ctx, &GoodsItem{
+		GoodsCode: "3070401000000000000", TaxRate: "0", ZeroRateFlag: "1", FavouredPolicyName: "免税",
+	}))
+	assert.ErrorIs(t, c.Validate(ctx, &GoodsItem{GoodsCode: "3070401000000000000", TaxRate: "0.13"}), ErrInvalidTaxCode)
+	assert.ErrorIs(t, c.Validate(ctx, &GoodsItem{GoodsCode: "307040"}), ErrInvalidTaxCode)
+
+	// 未收录的编码校验不通过
+	err := c.Validate(ctx, &GoodsItem{GoodsCode: "9999999999999999999", TaxRate: "0.13"})
+	assert.ErrorIs(t, err, ErrInvalidTaxCode)
+	assert.ErrorIs(t, err, ErrTaxCodeNotFound)
+
+	_, err = c.Lookup(ctx, "9999999999999999999")
+	assert.ErrorIs(t, err, ErrTaxCodeNotFound)
+
+	c.Fetcher = func(ctx context.Context, code string) (*TaxCode, error) {
+		return &TaxCode{Code: code, Name: "在线", TaxRates: []string{"0.13"}}, nil
+	}
+	assert.NoError(t, c.Validate(ctx, &GoodsItem{GoodsCode: "9999999999999999999", TaxRate: "0.13"}))
+	assert.ErrorIs(t, c.Validate(ctx, &GoodsItem{GoodsCode: "9999999999999999999", TaxRate: "0.06"}), ErrInvalidTaxCode)
+
+	_, err = LoadTaxCodeCatalogue(strings.NewReader(`{"codes":[]}`))
+	assert.Error(t, err)
+}
+
+func TestClient_TaxCodeFetcher(t *testing.T) {
+	var req QueryTaxCodeRequest
+
+	p := newFakePlatform(t)
+	p.handle("nuonuo.OpeMplatform.queryTaxClassificationCode", func(body []byte) (any, error) {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		return json.RawMessage(`[{
+			"bm": "3040201000000000000", "mc": "软件服务", "spbmjc": "信息技术服务",
+			"zzssl": "6%、3%", "zzstsgl": "简易征收、免税", "kyzt": "Y"
+		}]`), nil
+	})
+
+	tc, err := p.client().TaxCodeFetcher()(context.Background(), "3040201000000000000")
+	require.NoError(t, err)
+	assert.Equal(t, "3040201000000000000", req.GoodsCode)
+	assert.Equal(t, &TaxCode{
+		Code:      "3040201000000000000",
+		Name:      "软件服务",
+		ShortName: "信息技术服务",
+		TaxRates:  []string{"0.06", "0.03"},
+		Policies:  []string{"简易征收", "免税"},
+	}, tc)
+
+	tc, err = p.client().TaxCodeFetcher()(context.Background(), "3040202000000000000")
+	require.NoError(t, err)
+	assert.Nil(t, tc)
+}
@@ -0,0 +1,332 @@
+package nuonuo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+const listNameMaxLen = 46 // 清单名称最大长度（字符）
+
+var (
+	ErrInvalidTemplate  = errors.New("invalid invoice template")
+	ErrTemplateNotFound = errors.New("invoice template not found")
+)
+
+// InvoiceTemplate 发票模板，由订单默认值、标准明细行与备注、清单名称模板组成。
+//
+// Remark 与 ListName 为 text/template 模板，渲染时引用不存在的字段会报错。
+type InvoiceTemplate struct {
+	Name     string                `json:"name"`
+	Order    *InvoiceOrder         `json:"order,omitempty"`    // 订单默认值，不含明细
+	Lines    map[string]*GoodsItem `json:"lines,omitempty"`    // 标准明细行，按名称引用
+	Remark   string                `json:"remark,omitempty"`   // 备注模板
+	ListName string                `json:"listName,omitempty"` // 清单名称模板
+
+	remark   *template.Template
+	listName *template.Template
+}
+
+// compile 校验模板并解析备注与清单名称模板
+func (t *InvoiceTemplate) compile() error {
+	if t.Name == "" {
+		return fmt.Errorf("%w: name required", ErrInvalidTemplate)
+	}
+
+	if t.Order != nil {
+		if len(t.Order.InvoiceDetail) > 0 {
+			return fmt.Errorf("%w: %s: order must not contain invoiceDetail, use lines", ErrInvalidTemplate, t.Name)
+		}
+
+		if t.Order.Remark != "" || t.Order.ListName != "" {
+			return fmt.Errorf("%w: %s: use remark and listName templates", ErrInvalidTemplate, t.Name)
+		}
+	}
+
+	for key, line := range t.Lines {
+		if line == nil {
+			return fmt.Errorf("%w: %s: line %s is empty", ErrInvalidTemplate, t.Name, key)
+		}
+
+		if line.GoodsCode != "" && !isTaxCode(line.GoodsCode) {
+			return fmt.Errorf("%w: %s: line %s: goodsCode %q", ErrInvalidTemplate, t.Name, key, line.GoodsCode)
+		}
+
+		if err := CheckTaxPolicy(line); err != nil {
+			return fmt.Errorf("%w: %s: line %s: %w", ErrInvalidTemplate, t.Name, key, err)
+		}
+	}
+
+	var err error
+
+	if t.remark, err = parseTextTemplate(t.Name+".remark", t.Remark, remarkMaxLen); err != nil {
+		return err
+	}
+
+	if t.listName, err = parseTextTemplate(t.Name+".listName", t.ListName, listNameMaxLen); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseTextTemplate 解析模板，模板中的固定文本超过长度限制时报错
+func parseTextTemplate(name, text string, maxLen int) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+
+	if n := textLen(tmpl.Tree.Root); n > maxLen {
+		return nil, fmt.Errorf("%w: %s: fixed text is %d characters, exceeds %d", ErrInvalidTemplate, name, n, maxLen)
+	}
+
+	return tmpl, nil
+}
+
+func textLen(list *parse.ListNode) int {
+	n := 0
+	for _, node := range list.Nodes {
+		if text, ok := node.(*parse.TextNode); ok {
+			n += utf8.RuneCount(text.Text)
+		}
+	}
+
+	return n
+}
+
+func executeTextTemplate(tmpl *template.Template, data any, maxLen int) (string, error) {
+	if tmpl == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	s := buf.String()
+	if n := utf8.RuneCountInString(s); n > maxLen {
+		return "", fmt.Errorf("%s: rendered %d characters, exceeds %d", tmpl.Name(), n, maxLen)
+	}
+
+	return s, nil
+}
+
+// Render 使用业务数据渲染订单，返回的订单不含明细，可通过 Line 添加标准明细行
+func (t *InvoiceTemplate) Render(data any) (*InvoiceOrder, error) {
+	order := &InvoiceOrder{}
+	if t.Order != nil {
+		order = cloneTemplateOrder(t.Order)
+	}
+
+	var err error
+
+	if order.Remark, err = executeTextTemplate(t.remark, data, remarkMaxLen); err != nil {
+		return nil, fmt.Errorf("render template %s: %w", t.Name, err)
+	}
+
+	if order.ListName, err = executeTextTemplate(t.listName, data, listNameMaxLen); err != nil {
+		return nil, fmt.Errorf("render template %s: %w", t.Name, err)
+	}
+
+	if order.ListName != "" {
+		order.ListFlag = "1"
+	}
+
+	return order, nil
+}
+
+// cloneTemplateOrder 复制模板订单，指针与切片字段一并复制，避免渲染结果与模板共享数据
+func cloneTemplateOrder(o *InvoiceOrder) *InvoiceOrder {
+	order := *o
+	order.AdditionalElementList = clonePtrs(o.AdditionalElementList)
+	order.InvoiceTravellerTransportInfoList = clonePtrs(o.InvoiceTravellerTransportInfoList)
+	order.InvoiceBuildingInfo = clonePtr(o.InvoiceBuildingInfo)
+	order.InvoiceGoodsTransports = clonePtrs(o.InvoiceGoodsTransports)
+	order.RealPropertySellInfo = clonePtr(o.RealPropertySellInfo)
+	order.RealPropertyRentInfo = clonePtr(o.RealPropertyRentInfo)
+
+	return &order
+}
+
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+
+	v := *p
+
+	return &v
+}
+
+func clonePtrs[T any](s []*T) []*T {
+	if s == nil {
+		return nil
+	}
+
+	result := make([]*T, len(s))
+	for i, p := range s {
+		result[i] = clonePtr(p)
+	}
+
+	return result
+}
+
+// Line 返回标准明细行的副本
+func (t *InvoiceTemplate) Line(key string) (*GoodsItem, error) {
+	line, ok := t.Lines[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: line %s", ErrTemplateNotFound, t.Name, key)
+	}
+
+	item := *line
+
+	return &item, nil
+}
+
+// TemplateRegistry 发票模板注册表，可并发使用
+type TemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]*InvoiceTemplate
+}
+
+func NewTemplateRegistry() *TemplateRegistry {
+	return &TemplateRegistry{
+		templates: map[string]*InvoiceTemplate{},
+	}
+}
+
+// Register 校验并注册模板，同名模板会被替换
+func (r *TemplateRegistry) Register(t *InvoiceTemplate) error {
+	if err := t.compile(); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.templates[t.Name] = t
+
+	return nil
+}
+
+func (r *TemplateRegistry) Get(name string) (*InvoiceTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	t, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+	}
+
+	return t, nil
+}
+
+// Render 使用指定模板渲染订单
+func (r *TemplateRegistry) Render(name string, data any) (*InvoiceOrder, error) {
+	t, err := r.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.Render(data)
+}
+
+// LoadJSON 从 JSON 数组加载模板，出现未知字段时报错。
+// 任一模板校验失败时不注册任何模板。
+func (r *TemplateRegistry) LoadJSON(rd io.Reader) error {
+	dec := json.NewDecoder(rd)
+	dec.DisallowUnknownFields()
+
+	templates := []*InvoiceTemplate{}
+	if err := dec.Decode(&templates); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+
+	return r.registerAll(templates)
+}
+
+// LoadYAML 从 YAML 列表加载模板，字段名与 JSON 一致，税率、金额等字段需加引号
+func (r *TemplateRegistry) LoadYAML(rd io.Reader) error {
+	var v any
+	if err := yaml.NewDecoder(rd).Decode(&v); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+
+	// 转为 JSON 后解析，以复用结构体的 json 标签
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidTemplate, err)
+	}
+
+	return r.LoadJSON(bytes.NewReader(data))
+}
+
+// LoadFile 按扩展名（.json、.yaml、.yml）加载模板文件
+func (r *TemplateRegistry) LoadFile(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		err = r.LoadJSON(f)
+	case ".yaml", ".yml":
+		err = r.LoadYAML(f)
+	default:
+		return fmt.Errorf("%w: unsupported file %s", ErrInvalidTemplate, name)
+	}
+
+	if err != nil {
+		return fmt.Errorf("load %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (r *TemplateRegistry) registerAll(templates []*InvoiceTemplate) error {
+	names := map[string]bool{}
+
+	for _, t := range templates {
+		if t == nil {
+			return fmt.Errorf("%w: empty template", ErrInvalidTemplate)
+		}
+
+		if err := t.compile(); err != nil {
+			return err
+		}
+
+		if names[t.Name] {
+			return fmt.Errorf("%w: duplicate name %s", ErrInvalidTemplate, t.Name)
+		}
+
+		names[t.Name] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, t := range templates {
+		r.templates[t.Name] = t
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package nuonuo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTemplatesYAML = `
+- name: construction
+  order:
+    invoiceLine: pc
+    specificFactor: "3"
+  lines:
+    service:
+      goodsName: 建筑服务
+      goodsCode: "3050100000000000000"
+      taxRate: "0.09"
+  remark: "订单号：{{.OrderID}} 合同号：{{.ContractNo}} 项目地址：{{.Address}}"
+`
+
+func TestTemplateRegistry(t *testing.T) {
+	r := NewTemplateRegistry()
+	require.NoError(t, r.LoadYAML(strings.NewReader(testTemplatesYAML)))
+
+	data := map[string]string{"OrderID": "A001", "ContractNo": "HT-01", "Address": "杭州市"}
+	order, err := r.Render("construction", data)
+	require.NoError(t, err)
+	assert.Equal(t, "订单号：A001 合同号：HT-01 项目地址：杭州市", order.Remark)
+	assert.Equal(t, InvoiceLineAllElectronicNormal, order.InvoiceLine)
+
+	tmpl, err := r.Get("construction")
+	require.NoError(t, err)
+
+	line, err := tmpl.Line("service")
+	require.NoError(t, err)
+	line.Num = "1"
+	assert.Empty(t, tmpl.Lines["service"].Num)
+
+	_, err = r.Render("construction", map[string]string{"OrderID": "A001"})
+	assert.Error(t, err)
+
+	_, err = r.Render("missing", data)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
+
+func TestInvoiceTemplate_RenderCopiesOrder(t *testing.T) {
+	tmpl := &InvoiceTemplate{Name: "construction", Order: &InvoiceOrder{
+		InvoiceBuildingInfo:   &ConstructionInfo{ItemName: "项目"},
+		AdditionalElementList: []*AdditionalElement{{ElementName: "合同号"}},
+	}}
+	require.NoError(t, tmpl.compile())
+
+	order, err := tmpl.Render(nil)
+	require.NoError(t, err)
+
+	// 修改渲染结果不影响模板
+	order.InvoiceBuildingInfo.ItemName = "其他项目"
+	order.AdditionalElementList[0].ElementValue = "HT-01"
+	order.AdditionalElementList = append(order.AdditionalElementList, &AdditionalElement{})
+
+	assert.Equal(t, "项目", tmpl.Order.InvoiceBuildingInfo.ItemName)
+	assert.Empty(t, tmpl.Order.AdditionalElementList[0].ElementValue)
+	assert.Len(t, tmpl.Order.AdditionalElementList, 1)
+}
+
+func TestTemplateRegistry_LoadInvalid(t *testing.T) {
+	cases := map[string]string{
+		"unknown field": `[{"name": "a", "order": {"foo": "1"}}]`,
+		"bad template":  `[{"name": "a", "remark": "{{.OrderID"}]`,
+		"too long":      `[{"name": "a", "listName": "` + strings.Repeat("清", listNameMaxLen+1) + `"}]`,
+		"bad goodsCode": `[{"name": "a", "lines": {"x": {"goodsCode": "123"}}}]`,
+		"duplicate":     `[{"name": "a"}, {"name": "a"}]`,
+	}
+
+	for name, input := range cases {
+		r := NewTemplateRegistry()
+		assert.ErrorIs(t, r.LoadJSON(strings.NewReader(input)), ErrInvalidTemplate, name)
+	}
+}
@@ -0,0 +1,210 @@
+package nuonuo
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	datetimeLayout = "2006-01-02 15:04:05"
+	dateLayout     = "2006-01-02"
+)
+
+// shanghai 诺税通接口使用的时区
+var shanghai = time.FixedZone("Asia/Shanghai", 8*60*60)
+
+// Time 诺税通接口时间，统一按上海时区处理。
+//
+// 解析时兼容接口中出现的各种格式：毫秒时间戳（数字或字符串）、秒级时间戳、
+// "2006-01-02 15:04:05"、"2006-01-02" 与紧凑格式 "20060102150405"、"20060102"，
+// 空值、null 与 0 解析为零值。
+// 序列化为 "2006-01-02 15:04:05"，零值序列化为空字符串。
+// BillTime 等要求毫秒时间戳的参数不能使用 Time，需使用 Timestamp。
+type Time struct {
+	time.Time
+}
+
+func NewTime(t time.Time) Time {
+	return Time{Time: t.In(shanghai)}
+}
+
+// TimeFromMillis 毫秒时间戳转为 Time，0 返回零值
+func TimeFromMillis(ms int64) Time {
+	if ms == 0 {
+		return Time{}
+	}
+
+	return NewTime(time.UnixMilli(ms))
+}
+
+// ParseTime 解析接口返回的时间字符串
+func ParseTime(s string) (Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "0" {
+		return Time{}, nil
+	}
+
+	if isDigits(s) && (len(s) == 8 || len(s) == 14) {
+		// 紧凑格式 20060102、20060102150405
+		t, err := time.ParseInLocation("20060102150405"[:len(s)], s, shanghai)
+		if err != nil {
+			return Time{}, fmt.Errorf("parse time %q: %w", s, err)
+		}
+
+		return Time{Time: t}, nil
+	}
+
+	if isDigits(s) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return Time{}, fmt.Errorf("parse time %q: %w", s, err)
+		}
+
+		// 10位及以下为秒级时间戳
+		if len(s) <= 10 {
+			return NewTime(time.Unix(n, 0)), nil
+		}
+
+		return TimeFromMillis(n), nil
+	}
+
+	for _, layout := range []string{datetimeLayout, dateLayout} {
+		if t, err := time.ParseInLocation(layout, s, shanghai); err == nil {
+			return Time{Time: t}, nil
+		}
+	}
+
+	return Time{}, fmt.Errorf("parse time %q: unknown format", s)
+}
+
+func (t *Time) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*t = Time{}
+		return nil
+	}
+
+	s := string(data)
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := ParseTime(s)
+	if err != nil {
+		return err
+	}
+
+	*t = parsed
+
+	return nil
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// String 返回 "2006-01-02 15:04:05" 格式，零值返回空字符串
+func (t Time) String() string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.In(shanghai).Format(datetimeLayout)
+}
+
+// Date 返回 "2006-01-02" 格式，零值返回空字符串
+func (t Time) Date() string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.In(shanghai).Format(dateLayout)
+}
+
+// Millis 返回毫秒时间戳，零值返回0
+func (t Time) Millis() int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixMilli()
+}
+
+// Timestamp 返回毫秒时间戳字符串，用于 BillTime 等时间戳格式的请求参数
+func (t Time) Timestamp() string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// parseTimeLenient 解析响应中的时间字符串，无法解析时返回零值
+func parseTimeLenient(s string) time.Time {
+	t, err := ParseTime(s)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t.Time
+}
+
+// IssuedAt 开票时间
+func (item *InvoiceResultItem) IssuedAt() time.Time {
+	if item.InvoiceTime != 0 {
+		return TimeFromMillis(item.InvoiceTime).Time
+	}
+
+	return TimeFromMillis(item.InvoiceDate).Time
+}
+
+// CreatedAt 订单创建时间
+func (item *InvoiceResultItem) CreatedAt() time.Time {
+	return TimeFromMillis(item.CreateTime).Time
+}
+
+// UpdatedAt 订单更新时间
+func (item *InvoiceResultItem) UpdatedAt() time.Time {
+	return TimeFromMillis(item.UpdateTime).Time
+}
+
+// InvalidatedAt 作废时间，未作废时为零值
+func (item *InvoiceResultItem) InvalidatedAt() time.Time {
+	return parseTimeLenient(item.InvalidTime)
+}
+
+// BlueIssuedAt 蓝字发票开票时间
+func (item *InvoiceRedConfirmItem) BlueIssuedAt() time.Time {
+	return parseTimeLenient(item.BlueInvoiceTime)
+}
+
+// BilledAt 红字确认单申请时间
+func (item *InvoiceRedConfirmItem) BilledAt() time.Time {
+	return parseTimeLenient(item.BillTime)
+}
+
+// ConfirmedAt 红字确认单确认时间，未确认时为零值
+func (item *InvoiceRedConfirmItem) ConfirmedAt() time.Time {
+	return parseTimeLenient(item.ConfirmTime)
+}
+
+// SetInvoiceDate 设置开票日期
+func (o *InvoiceOrder) SetInvoiceDate(t time.Time) {
+	o.InvoiceDate = NewTime(t).String()
+}
+
+// SetBillTime 设置红字确认单填开时间
+func (r *SaveInvoiceRedConfirmRequest) SetBillTime(t time.Time) {
+	r.BillTime = NewTime(t).Timestamp()
+}
+
+// IssuedAt 开票时间
+func (e *InvoiceEvent) IssuedAt() time.Time {
+	return TimeFromMillis(e.InvoiceTime).Time
+}
@@ -0,0 +1,76 @@
+package nuonuo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTime_UnmarshalJSON(t *testing.T) {
+	want := time.Date(2024, 3, 5, 14, 30, 0, 0, shanghai)
+
+	for _, input := range []string{
+		`1709620200000`,
+		`"1709620200000"`,
+		`"1709620200"`,
+		`"2024-03-05 14:30:00"`,
+		`"2024-03-05 14:30:00.0"`,
+		`"20240305143000"`,
+	} {
+		var v Time
+		require.NoError(t, json.Unmarshal([]byte(input), &v), input)
+		assert.True(t, want.Equal(v.Time), input)
+		assert.Equal(t, "2024-03-05 14:30:00", v.String(), input)
+	}
+
+	for _, input := range []string{`null`, `""`, `0`, `"0"`} {
+		var v Time
+		require.NoError(t, json.Unmarshal([]byte(input), &v), input)
+		assert.True(t, v.IsZero(), input)
+	}
+
+	var v Time
+	assert.Error(t, json.Unmarshal([]byte(`"2024/03/05"`), &v))
+
+	var d Time
+	require.NoError(t, json.Unmarshal([]byte(`"20240305"`), &d))
+	assert.Equal(t, "2024-03-05", d.Date())
+}
+
+func TestTime_MarshalJSON(t *testing.T) {
+	data, err := json.Marshal(NewTime(time.Date(2024, 3, 5, 6, 30, 0, 0, time.UTC)))
+	require.NoError(t, err)
+	assert.Equal(t, `"2024-03-05 14:30:00"`, string(data))
+
+	data, err = json.Marshal(Time{})
+	require.NoError(t, err)
+	assert.Equal(t, `""`, string(data))
+
+	assert.Equal(t, "1709620200000", NewTime(time.Unix(1709620200, 0)).Timestamp())
+}
+
+func TestRequest_SetTime(t *testing.T) {
+	at := time.Unix(1709620200, 0).UTC()
+
+	order := &InvoiceOrder{}
+	order.SetInvoiceDate(at)
+	assert.Equal(t, "2024-03-05 14:30:00", order.InvoiceDate)
+
+	req := &SaveInvoiceRedConfirmRequest{}
+	req.SetBillTime(at)
+	assert.Equal(t, "1709620200000", req.BillTime)
+
+	// 零值不设置时间
+	req.SetBillTime(time.Time{})
+	assert.Empty(t, req.BillTime)
+}
+
+func TestInvoiceResultItem_IssuedAt(t *testing.T) {
+	item := &InvoiceResultItem{InvoiceDate: 1709620200000, InvalidTime: "2024-03-06 09:00:00"}
+	assert.Equal(t, "2024-03-05 14:30:00", NewTime(item.IssuedAt()).String())
+	assert.Equal(t, time.Date(2024, 3, 6, 9, 0, 0, 0, shanghai), item.InvalidatedAt())
+	assert.True(t, item.CreatedAt().IsZero())
+}
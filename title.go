@@ -0,0 +1,143 @@
+package nuonuo
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const titleMinKeywordLen = 2
+
+// ApplyTo 将企业抬头写入订单的购方信息
+func (t *EnterpriseTitle) ApplyTo(order *InvoiceOrder) {
+	order.BuyerName = t.Name
+	order.BuyerTaxNum = t.TaxNum
+	order.BuyerAddress = t.Address
+	order.BuyerTel = t.Tel
+	order.BuyerAccount = strings.TrimSpace(t.Bank + " " + t.BankAccount)
+}
+
+// TitleCache 企业抬头查询结果缓存
+type TitleCache interface {
+	Get(ctx context.Context, keyword string) ([]*EnterpriseTitle, bool, error)
+	Set(ctx context.Context, keyword string, titles []*EnterpriseTitle) error
+}
+
+type lruEntry struct {
+	keyword   string
+	titles    []*EnterpriseTitle
+	expiresAt time.Time
+}
+
+type lruTitleCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	ll      *list.List
+	entries map[string]*list.Element
+}
+
+// NewLRUTitleCache 基于内存的 LRU 缓存，最多保存 size 个关键字，size 不大于0时不限制，每条记录 ttl 后过期
+func NewLRUTitleCache(size int, ttl time.Duration) TitleCache {
+	return &lruTitleCache{
+		size:    size,
+		ttl:     ttl,
+		ll:      list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *lruTitleCache) Get(ctx context.Context, keyword string) ([]*EnterpriseTitle, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[keyword]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := el.Value.(*lruEntry) // nolint: forcetypeassert
+	if time.Now().After(entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.entries, keyword)
+
+		return nil, false, nil
+	}
+
+	c.ll.MoveToFront(el)
+
+	return entry.titles, true, nil
+}
+
+func (c *lruTitleCache) Set(ctx context.Context, keyword string, titles []*EnterpriseTitle) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry{keyword: keyword, titles: titles, expiresAt: time.Now().Add(c.ttl)}
+
+	if el, ok := c.entries[keyword]; ok {
+		el.Value = entry
+		c.ll.MoveToFront(el)
+
+		return nil
+	}
+
+	c.entries[keyword] = c.ll.PushFront(entry)
+
+	for c.size > 0 && c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).keyword) // nolint: forcetypeassert
+	}
+
+	return nil
+}
+
+// TitleLookup 带缓存的企业抬头查询，用于购方名称自动补全
+type TitleLookup struct {
+	client *Client
+	cache  TitleCache
+}
+
+// NewTitleLookup cache 为空时不缓存
+func NewTitleLookup(c *Client, cache TitleCache) *TitleLookup {
+	return &TitleLookup{
+		client: c,
+		cache:  cache,
+	}
+}
+
+// Search 按关键字查询企业抬头，关键字少于两个字时不查询。
+// 写入缓存失败不影响查询结果，下次查询时重新请求接口。
+func (l *TitleLookup) Search(ctx context.Context, keyword string) ([]*EnterpriseTitle, error) {
+	keyword = strings.TrimSpace(keyword)
+	if utf8.RuneCountInString(keyword) < titleMinKeywordLen {
+		return nil, nil
+	}
+
+	if l.cache != nil {
+		titles, ok, err := l.cache.Get(ctx, keyword)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return titles, nil
+		}
+	}
+
+	titles, err := l.client.QueryEnterpriseTitle(ctx, &QueryEnterpriseTitleRequest{Keyword: keyword})
+	if err != nil {
+		return nil, err
+	}
+
+	if l.cache != nil {
+		_ = l.cache.Set(ctx, keyword, titles)
+	}
+
+	return titles, nil
+}
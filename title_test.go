@@ -0,0 +1,134 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func cachedKeywords(t *testing.T, c TitleCache, keywords ...string) []string {
+	t.Helper()
+
+	found := []string{}
+	for _, keyword := range keywords {
+		_, ok, err := c.Get(context.Background(), keyword)
+		require.NoError(t, err)
+
+		if ok {
+			found = append(found, keyword)
+		}
+	}
+
+	return found
+}
+
+func TestLRUTitleCache_Eviction(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUTitleCache(2, time.Hour)
+
+	require.NoError(t, c.Set(ctx, "A", []*EnterpriseTitle{{Name: "A"}}))
+	require.NoError(t, c.Set(ctx, "B", nil))
+
+	// 访问 A 后 B 成为最久未使用的记录
+	titles, ok, err := c.Get(ctx, "A")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "A", titles[0].Name)
+
+	require.NoError(t, c.Set(ctx, "C", nil))
+	assert.Equal(t, []string{"A", "C"}, cachedKeywords(t, c, "A", "B", "C"))
+
+	// 更新已有记录不淘汰其他记录
+	require.NoError(t, c.Set(ctx, "A", []*EnterpriseTitle{{Name: "A2"}}))
+	assert.Equal(t, []string{"A", "C"}, cachedKeywords(t, c, "A", "B", "C"))
+
+	titles, _, _ = c.Get(ctx, "A")
+	assert.Equal(t, "A2", titles[0].Name)
+}
+
+func TestLRUTitleCache_Expiry(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRUTitleCache(10, 10*time.Millisecond)
+
+	require.NoError(t, c.Set(ctx, "A", nil))
+	assert.Equal(t, []string{"A"}, cachedKeywords(t, c, "A"))
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, cachedKeywords(t, c, "A"))
+}
+
+func TestLRUTitleCache_Unlimited(t *testing.T) {
+	ctx := context.Background()
+
+	for _, size := range []int{0, -1} {
+		c := NewLRUTitleCache(size, time.Hour)
+
+		keywords := []string{"A", "B", "C", "D"}
+		for _, keyword := range keywords {
+			require.NoError(t, c.Set(ctx, keyword, nil))
+		}
+
+		assert.Equal(t, keywords, cachedKeywords(t, c, keywords...), "size %d", size)
+	}
+}
+
+// failingTitleCache 写入总是失败的缓存
+type failingTitleCache struct{}
+
+func (failingTitleCache) Get(ctx context.Context, keyword string) ([]*EnterpriseTitle, bool, error) {
+	return nil, false, nil
+}
+
+func (failingTitleCache) Set(ctx context.Context, keyword string, titles []*EnterpriseTitle) error {
+	return errors.New("cache unavailable")
+}
+
+func newTitlePlatform(t *testing.T) *fakePlatform {
+	p := newFakePlatform(t)
+	p.handle("nuonuo.speedBilling.prefixQuery", func(body []byte) (any, error) {
+		var req QueryEnterpriseTitleRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		return []*EnterpriseTitle{{Name: req.Keyword + "有限公司", TaxNum: "339901999999199"}}, nil
+	})
+
+	return p
+}
+
+func TestTitleLookup_Search(t *testing.T) {
+	ctx := context.Background()
+	p := newTitlePlatform(t)
+	l := NewTitleLookup(p.client(), NewLRUTitleCache(10, time.Hour))
+
+	titles, err := l.Search(ctx, " 诺诺 ")
+	require.NoError(t, err)
+	require.Len(t, titles, 1)
+	assert.Equal(t, "诺诺有限公司", titles[0].Name)
+
+	_, err = l.Search(ctx, "诺诺")
+	require.NoError(t, err)
+	assert.Equal(t, 1, p.count("nuonuo.speedBilling.prefixQuery"))
+
+	titles, err = l.Search(ctx, "诺")
+	require.NoError(t, err)
+	assert.Nil(t, titles)
+	assert.Equal(t, 1, p.count("nuonuo.speedBilling.prefixQuery"))
+}
+
+func TestTitleLookup_SearchCacheSetError(t *testing.T) {
+	p := newTitlePlatform(t)
+	l := NewTitleLookup(p.client(), failingTitleCache{})
+
+	// 写入缓存失败时仍返回查询结果
+	titles, err := l.Search(context.Background(), "诺诺")
+	require.NoError(t, err)
+	require.Len(t, titles, 1)
+	assert.Equal(t, "诺诺有限公司", titles[0].Name)
+}
@@ -0,0 +1,331 @@
+package nuonuo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TrackedInvoice 跟踪中的发票
+type TrackedInvoice struct {
+	SerialNo     string    `json:"serialNo"`
+	OrderNo      string    `json:"orderNo"`
+	RegisteredAt time.Time `json:"registeredAt"`
+	NextPollAt   time.Time `json:"nextPollAt"` // 未收到推送时下次轮询的时间
+	Polls        int       `json:"polls"`
+}
+
+// TrackerStore 保存跟踪中的发票
+type TrackerStore interface {
+	Add(ctx context.Context, inv *TrackedInvoice) error
+	Update(ctx context.Context, inv *TrackedInvoice) error
+
+	// Get 返回跟踪中的发票，未在跟踪中时返回 nil
+	Get(ctx context.Context, serialNo string) (*TrackedInvoice, error)
+
+	// Remove 移除发票，返回发票此前是否处于跟踪中
+	Remove(ctx context.Context, serialNo string) (bool, error)
+
+	// Due 返回 NextPollAt 不晚于 now 的发票，最多 limit 条
+	Due(ctx context.Context, now time.Time, limit int) ([]*TrackedInvoice, error)
+}
+
+type memoryTrackerStore struct {
+	mu       sync.Mutex
+	invoices map[string]TrackedInvoice
+}
+
+// NewMemoryTrackerStore 基于内存的跟踪状态存储，进程退出后状态丢失，仅用于测试
+func NewMemoryTrackerStore() TrackerStore {
+	return &memoryTrackerStore{
+		invoices: map[string]TrackedInvoice{},
+	}
+}
+
+func (s *memoryTrackerStore) Add(ctx context.Context, inv *TrackedInvoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.invoices[inv.SerialNo] = *inv
+
+	return nil
+}
+
+func (s *memoryTrackerStore) Update(ctx context.Context, inv *TrackedInvoice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.invoices[inv.SerialNo]; ok {
+		s.invoices[inv.SerialNo] = *inv
+	}
+
+	return nil
+}
+
+func (s *memoryTrackerStore) Get(ctx context.Context, serialNo string) (*TrackedInvoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	inv, ok := s.invoices[serialNo]
+	if !ok {
+		return nil, nil
+	}
+
+	return &inv, nil
+}
+
+func (s *memoryTrackerStore) Remove(ctx context.Context, serialNo string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.invoices[serialNo]
+	delete(s.invoices, serialNo)
+
+	return ok, nil
+}
+
+func (s *memoryTrackerStore) Due(ctx context.Context, now time.Time, limit int) ([]*TrackedInvoice, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := []*TrackedInvoice{}
+	for _, inv := range s.invoices {
+		if !inv.NextPollAt.After(now) {
+			inv := inv
+			due = append(due, &inv)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextPollAt.Before(due[j].NextPollAt)
+	})
+
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	return due, nil
+}
+
+var errTrackerRunning = errors.New("invoice tracker already running")
+
+type TrackerOptions struct {
+	Overdue      time.Duration // 开票后多久未收到推送即开始轮询，默认1分钟
+	PollInterval time.Duration // 两次轮询同一张发票的间隔，默认30秒
+	ScanInterval time.Duration // 检查待轮询发票的间隔，默认10秒
+	BatchSize    int           // 每轮最多轮询的发票数，默认200
+}
+
+// InvoiceTracker 汇总推送与轮询结果，将每张发票的最终状态交给 handler 处理。
+//
+// handler 返回 nil 后才移除跟踪记录；返回错误时保留记录，推送由平台重新推送，
+// 轮询在 PollInterval 后重试，进程中断后重启也会继续轮询，最终状态事件不会丢失。
+// 同一进程内同一张发票不会并发调用 handler，handler 成功后不再调用；
+// handler 成功但移除记录前进程中断时会再次调用，handler 需支持幂等处理。
+//
+//	tracker := NewInvoiceTracker(c, store, func(ctx context.Context, event *InvoiceEvent) error {
+//		return saveInvoiceResult(ctx, event)
+//	}, nil)
+//	callbacks.OnInvoice(tracker.HandleCallback)
+//	go tracker.Run(ctx)
+type InvoiceTracker struct {
+	client  *Client
+	store   TrackerStore
+	handler InvoiceEventHandler
+	opts    TrackerOptions
+
+	mu       sync.Mutex
+	inflight map[string]bool // 正在处理的发票
+	running  bool
+}
+
+func NewInvoiceTracker(
+	c *Client, store TrackerStore, handler InvoiceEventHandler, opts *TrackerOptions,
+) *InvoiceTracker {
+	o := TrackerOptions{
+		Overdue:      time.Minute,
+		PollInterval: 30 * time.Second,
+		ScanInterval: 10 * time.Second,
+		BatchSize:    200,
+	}
+
+	if opts != nil {
+		if opts.Overdue > 0 {
+			o.Overdue = opts.Overdue
+		}
+
+		if opts.PollInterval > 0 {
+			o.PollInterval = opts.PollInterval
+		}
+
+		if opts.ScanInterval > 0 {
+			o.ScanInterval = opts.ScanInterval
+		}
+
+		if opts.BatchSize > 0 {
+			o.BatchSize = opts.BatchSize
+		}
+	}
+
+	return &InvoiceTracker{
+		client:   c,
+		store:    store,
+		handler:  handler,
+		opts:     o,
+		inflight: map[string]bool{},
+	}
+}
+
+// Track 开始跟踪发票，在 OpenInvoice 或 FastInvoiceRed 成功后调用
+func (t *InvoiceTracker) Track(ctx context.Context, serialNo, orderNo string) error {
+	now := time.Now()
+
+	return t.store.Add(ctx, &TrackedInvoice{
+		SerialNo:     serialNo,
+		OrderNo:      orderNo,
+		RegisteredAt: now,
+		NextPollAt:   now.Add(t.opts.Overdue),
+	})
+}
+
+// HandleCallback 处理开票结果推送，可注册到 CallbackHandler。
+// handler 处理失败时返回错误，平台会重新推送。
+func (t *InvoiceTracker) HandleCallback(ctx context.Context, event *InvoiceEvent) error {
+	if !event.Status.IsTerminal() {
+		return nil
+	}
+
+	return t.emit(ctx, event)
+}
+
+// emit 将跟踪中发票的最终状态交给 handler，处理成功后移除跟踪记录。
+// 发票未在跟踪中或正在处理时忽略。
+func (t *InvoiceTracker) emit(ctx context.Context, event *InvoiceEvent) error {
+	t.mu.Lock()
+	if t.inflight[event.SerialNo] {
+		t.mu.Unlock()
+		return nil
+	}
+
+	t.inflight[event.SerialNo] = true
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		delete(t.inflight, event.SerialNo)
+		t.mu.Unlock()
+	}()
+
+	inv, err := t.store.Get(ctx, event.SerialNo)
+	if err != nil || inv == nil {
+		return err
+	}
+
+	if err := t.handler(ctx, event); err != nil {
+		return err
+	}
+
+	_, err = t.store.Remove(ctx, event.SerialNo)
+
+	return err
+}
+
+// Run 轮询超时未收到推送的发票，直到 ctx 结束。同一时间只能运行一次。
+func (t *InvoiceTracker) Run(ctx context.Context) error {
+	t.mu.Lock()
+	if t.running {
+		t.mu.Unlock()
+		return errTrackerRunning
+	}
+
+	t.running = true
+	t.mu.Unlock()
+
+	defer func() {
+		t.mu.Lock()
+		t.running = false
+		t.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(t.opts.ScanInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := t.poll(ctx); err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (t *InvoiceTracker) poll(ctx context.Context) error {
+	now := time.Now()
+
+	due, err := t.store.Due(ctx, now, t.opts.BatchSize)
+	if err != nil || len(due) == 0 {
+		return err
+	}
+
+	serialNos := make([]string, 0, len(due))
+	for _, inv := range due {
+		serialNos = append(serialNos, inv.SerialNo)
+	}
+
+	result, err := t.client.QueryInvoicesBatch(ctx, &QueryInvoicesBatchRequest{SerialNos: serialNos})
+	if result == nil {
+		return err
+	}
+
+	errs := []error{err}
+
+	for _, inv := range due {
+		item, ok := result.BySerialNo[inv.SerialNo]
+		if ok && item.Status.IsTerminal() {
+			// handler 处理失败时保留记录，下次轮询重试
+			err := t.emit(ctx, newInvoiceEvent(item))
+			if err == nil {
+				continue
+			}
+
+			errs = append(errs, err)
+		}
+
+		inv.Polls++
+		inv.NextPollAt = now.Add(t.opts.PollInterval)
+
+		if err := t.store.Update(ctx, inv); err != nil {
+			return err
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func newInvoiceEvent(item *InvoiceResultItem) *InvoiceEvent {
+	return &InvoiceEvent{
+		SerialNo:                  item.SerialNo,
+		OrderNo:                   item.OrderNo,
+		Status:                    item.Status,
+		StatusMsg:                 item.FailCause,
+		InvoiceCode:               item.InvoiceCode,
+		InvoiceNo:                 item.InvoiceNo,
+		AllElectronicInvoiceNumbe: item.AllElectronicInvoiceNumbe,
+		InvoiceTime:               item.InvoiceTime,
+		ExTaxAmount:               item.ExTaxAmount,
+		TaxAmount:                 item.TaxAmount,
+		OrderAmount:               item.OrderAmount,
+		CheckCode:                 item.CheckCode,
+		BuyerName:                 item.PayerName,
+		BuyerTaxNum:               item.PayerTaxNo,
+		PdfURL:                    item.PdfURL,
+		PictureURL:                item.PictureURL,
+		OfdURL:                    item.OfdURL,
+	}
+}
@@ -0,0 +1,156 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventRecorder 记录 handler 收到的事件，前 failures 次处理返回错误
+type eventRecorder struct {
+	mu       sync.Mutex
+	failures int
+	calls    int
+	events   []*InvoiceEvent
+}
+
+func (r *eventRecorder) handle(ctx context.Context, event *InvoiceEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls++
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("handler failed")
+	}
+
+	r.events = append(r.events, event)
+
+	return nil
+}
+
+func (r *eventRecorder) handled() []*InvoiceEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*InvoiceEvent(nil), r.events...)
+}
+
+func TestInvoiceTracker_HandleCallback(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTrackerStore()
+	rec := &eventRecorder{failures: 1}
+	tracker := NewInvoiceTracker(nil, store, rec.handle, nil)
+
+	require.NoError(t, tracker.Track(ctx, "S1", "O1"))
+
+	event := &InvoiceEvent{SerialNo: "S1", OrderNo: "O1", Status: InvoiceStatusIssuing}
+	require.NoError(t, tracker.HandleCallback(ctx, event))
+	assert.Zero(t, rec.calls)
+
+	// 处理失败时保留跟踪记录，平台重新推送时再次处理
+	event = &InvoiceEvent{SerialNo: "S1", OrderNo: "O1", Status: InvoiceStatusCompleted}
+	require.Error(t, tracker.HandleCallback(ctx, event))
+
+	inv, err := store.Get(ctx, "S1")
+	require.NoError(t, err)
+	assert.NotNil(t, inv)
+
+	require.NoError(t, tracker.HandleCallback(ctx, event))
+	require.NoError(t, tracker.HandleCallback(ctx, event))
+	assert.Equal(t, []*InvoiceEvent{event}, rec.handled())
+
+	inv, err = store.Get(ctx, "S1")
+	require.NoError(t, err)
+	assert.Nil(t, inv)
+
+	// 未跟踪的发票忽略
+	require.NoError(t, tracker.HandleCallback(ctx, &InvoiceEvent{SerialNo: "S2", Status: InvoiceStatusFailed}))
+	assert.Len(t, rec.handled(), 1)
+}
+
+func TestInvoiceTracker_Poll(t *testing.T) {
+	p := newFakePlatform(t)
+	p.handle("nuonuo.OpeMplatform.queryInvoiceResult", func(body []byte) (any, error) {
+		var req QueryInvoiceRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		items := []*InvoiceResultItem{}
+		for _, serialNo := range req.SerialNos {
+			status := InvoiceStatusCompleted
+			if serialNo == "S2" {
+				status = InvoiceStatusIssuing
+			}
+
+			items = append(items, &InvoiceResultItem{SerialNo: serialNo, OrderNo: "O-" + serialNo, Status: status})
+		}
+
+		return items, nil
+	})
+
+	ctx := context.Background()
+	store := NewMemoryTrackerStore()
+	rec := &eventRecorder{failures: 1}
+	tracker := NewInvoiceTracker(p.client(), store, rec.handle, &TrackerOptions{
+		Overdue:      time.Millisecond,
+		PollInterval: time.Millisecond,
+		ScanInterval: 5 * time.Millisecond,
+	})
+
+	require.NoError(t, tracker.Track(ctx, "S1", "O-S1"))
+	require.NoError(t, tracker.Track(ctx, "S2", "O-S2"))
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan error)
+
+	go func() { done <- tracker.Run(runCtx) }()
+
+	// 未收到推送的发票由轮询得到最终状态，处理失败后下次轮询重试
+	require.Eventually(t, func() bool { return len(rec.handled()) == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	events := rec.handled()
+	assert.Equal(t, "S1", events[0].SerialNo)
+	assert.Equal(t, "O-S1", events[0].OrderNo)
+	assert.True(t, events[0].Status.IsSuccess())
+
+	inv, err := store.Get(ctx, "S1")
+	require.NoError(t, err)
+	assert.Nil(t, inv)
+
+	// 未到最终状态的发票继续跟踪
+	inv, err = store.Get(ctx, "S2")
+	require.NoError(t, err)
+	require.NotNil(t, inv)
+	assert.Positive(t, inv.Polls)
+}
+
+func TestInvoiceTracker_RunOnce(t *testing.T) {
+	tracker := NewInvoiceTracker(nil, NewMemoryTrackerStore(), (&eventRecorder{}).handle, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() { done <- tracker.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		tracker.mu.Lock()
+		defer tracker.mu.Unlock()
+
+		return tracker.running
+	}, time.Second, time.Millisecond)
+	assert.ErrorIs(t, tracker.Run(ctx), errTrackerRunning)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
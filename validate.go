@@ -0,0 +1,49 @@
+package nuonuo
+
+import "fmt"
+
+// LineError 明细行校验失败
+type LineError struct {
+	Index int // 明细行下标，从0开始
+	Err   error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("invoiceDetail[%d]: %v", e.Index, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// orderRules 开票前对订单执行的校验
+var orderRules = []func(order *InvoiceOrder) error{
+	checkTaxNums,
+	checkDeductionOrder,
+	checkSpecificFactor,
+}
+
+// lineRules 开票前对每个明细行执行的校验
+var lineRules = []func(item *GoodsItem) error{
+	CheckTaxPolicy,
+	CheckDeduction,
+}
+
+// Validate 开票前校验订单，OpenInvoice 在请求前调用。
+func (o *InvoiceOrder) Validate() error {
+	for _, rule := range orderRules {
+		if err := rule(o); err != nil {
+			return err
+		}
+	}
+
+	for i, item := range o.InvoiceDetail {
+		for _, rule := range lineRules {
+			if err := rule(item); err != nil {
+				return &LineError{Index: i, Err: err}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package nuonuo
+
+import (
+	"time"
+)
+
+// CancelAction 作废已开具发票的方式
+type CancelAction string
+
+const (
+	CancelByInvalidate  CancelAction = "invalidate" // 作废
+	CancelByRedReversal CancelAction = "red"        // 冲红
+	CancelNotAllowed    CancelAction = "none"       // 无法处理
+)
+
+// CancelActionFor 判断发票应作废还是冲红。
+// 只有当月开具的税控纸质发票可以作废，其余已开具的发票需冲红。
+func CancelActionFor(item *InvoiceResultItem, now time.Time) (CancelAction, string) {
+	switch item.Status {
+	case InvoiceStatusCompleted, InvoiceStatusSignFailed:
+	case InvoiceStatusInvalidated, InvoiceStatusInvalidating:
+		return CancelNotAllowed, "发票已作废"
+	default:
+		return CancelNotAllowed, "发票未开具成功"
+	}
+
+	if !isLegacyPaperLine(item.InvoiceKind) {
+		return CancelByRedReversal, "非税控纸质发票只能冲红"
+	}
+
+	issued := item.IssuedAt()
+	if issued.IsZero() {
+		return CancelNotAllowed, "缺少开票日期"
+	}
+
+	now = now.In(shanghai)
+
+	if issued.Year() != now.Year() || issued.Month() != now.Month() {
+		return CancelByRedReversal, "跨月发票只能冲红"
+	}
+
+	return CancelByInvalidate, ""
+}
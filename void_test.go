@@ -0,0 +1,57 @@
+package nuonuo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelActionFor(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, shanghai)
+	issued := time.Date(2024, 3, 1, 0, 30, 0, 0, shanghai).UnixMilli()
+	lastMonth := time.Date(2024, 2, 29, 23, 30, 0, 0, shanghai).UnixMilli()
+
+	item := &InvoiceResultItem{Status: InvoiceStatusCompleted, InvoiceKind: InvoiceLinePaperSpecial, InvoiceDate: issued}
+	action, _ := CancelActionFor(item, now)
+	assert.Equal(t, CancelByInvalidate, action)
+
+	item.InvoiceDate = lastMonth
+	action, _ = CancelActionFor(item, now)
+	assert.Equal(t, CancelByRedReversal, action)
+
+	item.InvoiceKind = InvoiceLineAllElectronicNormal
+	item.InvoiceDate = issued
+	action, _ = CancelActionFor(item, now)
+	assert.Equal(t, CancelByRedReversal, action)
+
+	item.Status = InvoiceStatusInvalidated
+	action, _ = CancelActionFor(item, now)
+	assert.Equal(t, CancelNotAllowed, action)
+}
+
+func TestClient_InvalidateInvoice(t *testing.T) {
+	p := newFakePlatform(t)
+
+	var req map[string]string
+	p.handle("nuonuo.OpeMplatform.invoiceCancellation", func(body []byte) (any, error) {
+		if err := json.Unmarshal(body, &req); err != nil {
+			return nil, err
+		}
+
+		return &InvalidateInvoiceResponse{InvoiceID: req["invoiceId"]}, nil
+	})
+
+	resp, err := p.client().InvalidateInvoice(context.Background(), &InvalidateInvoiceRequest{
+		InvoiceID: "S1", InvoiceCode: "033002000111", InvoiceNo: "12345678",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "S1", resp.InvoiceID)
+	assert.Equal(t, map[string]string{
+		"invoiceId": "S1", "invoiceCode": "033002000111", "invoiceNo": "12345678",
+	}, req)
+	assert.Equal(t, 1, p.count("nuonuo.OpeMplatform.invoiceCancellation"))
+}